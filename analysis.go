@@ -0,0 +1,469 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// defaultTopWords and maxTopWords bound how many frequent words an analysis lists.
+	defaultTopWords = 10
+	maxTopWords     = 100
+)
+
+// Rhyme patterns recognized in a verse.
+const (
+	rhymeCouplet   = "couplet"
+	rhymeAlternate = "alternate"
+	rhymeEnclosed  = "enclosed"
+	rhymeMonorhyme = "monorhyme"
+	rhymeFree      = "free"
+)
+
+// stopWords lists the words left out of frequency counts, by language.
+var stopWords = map[string]map[string]bool{
+	"en": wordSet(`a about above after again against all am an and any are as at be because been before being
+		below between both but by can could did do does doing down during each few for from further had has
+		have having he her here hers herself him himself his how i if in into is it its itself just me more
+		most my myself no nor not now of off on once only or other our ours ourselves out over own same she
+		should so some such than that the their theirs them themselves then there these they this those through
+		to too under until up very was we were what when where which while who whom why will with would you
+		your yours yourself yourselves i'm you're it's don't can't won't ain't oh yeah la na hey ooh`),
+	"ru": wordSet(`а без более бы был была были было быть в вам вас весь во вот все всего всех вы где да даже для
+		до его ее её если есть еще ещё же за здесь и из или им их к как ко когда кто ли либо мне может мы на
+		над надо наш не него нее неё нет ни них но ну о об однако он она они оно от очень по под при с со так
+		также такой там те тем то того тоже той только том ты у уже хотя чего чей чем что чтобы чье чья эта
+		эти это я мой моя мое моё мои твой твоя твои себя себе меня тебя тебе нам нас вами ей ему им ним ней
+		лишь будет будто вдруг опять снова ах ох эх ля`),
+}
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// lyricWords splits text into lowercase words; apostrophes inside words are kept, as in "don't".
+func lyricWords(text string) []string {
+	var words []string
+	var word strings.Builder
+	runes := []rune(strings.ToLower(text))
+	for i, r := range runes {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) ||
+			((r == '\'' || r == '’') && word.Len() > 0 && i+1 < len(runes) && unicode.IsLetter(runes[i+1]))
+		if inWord {
+			if r == '’' {
+				r = '\''
+			}
+			word.WriteRune(r)
+			continue
+		}
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// scriptLanguage guesses between Russian and English from the alphabet most letters are in.
+func scriptLanguage(text string) string {
+	cyrillic, latin := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	if cyrillic > latin {
+		return "ru"
+	}
+	return "en"
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aeiouyаеёиоуыэюя", r)
+}
+
+// syllables estimates the syllables of a word as its vowel groups. In English a final silent e
+// doesn't count; in Russian every vowel is a syllable.
+func syllables(word, language string) int {
+	count := 0
+	prevVowel := false
+	runes := []rune(word)
+	for _, r := range runes {
+		vowel := isVowel(r)
+		if vowel && (language == "ru" || !prevVowel) {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if language != "ru" && count > 1 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") {
+		count--
+	}
+	return max(count, 1)
+}
+
+// rhymeKey returns the part of a line's last word that has to match for two lines to rhyme: from its
+// last stressed-looking vowel group, approximated by the last vowel group, to the end, as "ight" in
+// "night". Russian also takes the consonant before it, as its rhymes lean on it more.
+func rhymeKey(line, language string) string {
+	words := lyricWords(line)
+	if len(words) == 0 {
+		return ""
+	}
+	runes := []rune(words[len(words)-1])
+	end := len(runes) - 1
+	for end >= 0 && !isVowel(runes[end]) {
+		end--
+	}
+	if end < 0 {
+		return string(runes)
+	}
+	start := end
+	for start > 0 && isVowel(runes[start-1]) {
+		start--
+	}
+	if language == "ru" && start > 0 && end == len(runes)-1 {
+		start--
+	}
+	return string(runes[start:])
+}
+
+// rhymeScheme labels the lines of a verse with letters, equal letters for lines that rhyme, and names
+// the pattern when it is a regular one.
+func rhymeScheme(lines []string, language string) (string, string) {
+	letters := map[string]byte{}
+	var scheme strings.Builder
+	next := byte('A')
+	for _, line := range lines {
+		key := rhymeKey(line, language)
+		letter, ok := letters[key]
+		if !ok || key == "" {
+			letter = next
+			if next < 'Z' {
+				next++
+			}
+			if key != "" {
+				letters[key] = letter
+			}
+		}
+		scheme.WriteByte(letter)
+	}
+	return scheme.String(), rhymePattern(scheme.String())
+}
+
+// rhymePattern names a scheme made of repeating AABB, ABAB or ABBA quatrains or couplets.
+func rhymePattern(scheme string) string {
+	n := len(scheme)
+	if n < 2 {
+		return rhymeFree
+	}
+	if strings.Count(scheme, scheme[:1]) == n {
+		return rhymeMonorhyme
+	}
+	matches := func(block int, ok func(b string) bool) bool {
+		if n%block != 0 {
+			return false
+		}
+		for i := 0; i < n; i += block {
+			if !ok(scheme[i : i+block]) {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case matches(2, func(b string) bool { return b[0] == b[1] }):
+		return rhymeCouplet
+	case matches(4, func(b string) bool { return b[0] == b[2] && b[1] == b[3] && b[0] != b[1] }):
+		return rhymeAlternate
+	case matches(4, func(b string) bool { return b[0] == b[3] && b[1] == b[2] && b[0] != b[1] }):
+		return rhymeEnclosed
+	}
+	return rhymeFree
+}
+
+// readability is the Flesch reading ease of the lyrics, taking each line as a sentence since lyrics are
+// rarely punctuated. Russian uses Oborneva's coefficients. Higher is easier, about 0 to 100.
+func readability(words []string, sentences int, language string) float64 {
+	if len(words) == 0 || sentences == 0 {
+		return 0
+	}
+	total := 0
+	for _, word := range words {
+		total += syllables(word, language)
+	}
+	wordsPerSentence := float64(len(words)) / float64(sentences)
+	syllablesPerWord := float64(total) / float64(len(words))
+	score := 206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord
+	if language == "ru" {
+		score = 206.835 - 1.3*wordsPerSentence - 60.1*syllablesPerWord
+	}
+	return math.Round(score*10) / 10
+}
+
+// wordCounts counts the words that aren't stop words in the language.
+func wordCounts(words []string, language string) map[string]int {
+	counts := map[string]int{}
+	for _, word := range words {
+		if !stopWords[language][word] && len([]rune(word)) > 1 {
+			counts[word]++
+		}
+	}
+	return counts
+}
+
+// topWords returns the most frequent words, ties broken alphabetically.
+func topWords(counts map[string]int, limit int) []WordCount {
+	top := make([]WordCount, 0, len(counts))
+	for word, count := range counts {
+		top = append(top, WordCount{Word: word, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Word < top[j].Word
+	})
+	return top[:min(limit, len(top))]
+}
+
+// analyzeLyrics computes the statistics of one song's lyrics from its sections, so headers like
+// [Chorus] aren't counted as words while a repeated chorus is. language may be empty, in which case
+// it is guessed from the alphabet; stop words exist for en and ru only. It also returns the words.
+func analyzeLyrics(sections []LyricSection, language string, limit int) (LyricsAnalysis, []string) {
+	var lines []string
+	for _, section := range sections {
+		lines = append(lines, section.Lines...)
+	}
+	text := strings.Join(lines, "\n")
+
+	primary := primaryLanguage(language)
+	if primary == "" {
+		primary = scriptLanguage(text)
+	}
+
+	words := lyricWords(text)
+	analysis := LyricsAnalysis{
+		Language:    primary,
+		WordCount:   len(words),
+		LineCount:   len(lines),
+		Readability: readability(words, len(lines), primary),
+		Verses:      []VerseRhyme{},
+	}
+
+	unique := map[string]bool{}
+	for _, word := range words {
+		unique[word] = true
+	}
+	analysis.UniqueWords = len(unique)
+	if len(words) > 0 {
+		analysis.LexicalDensity = math.Round(float64(len(unique))/float64(len(words))*1000) / 1000
+	}
+
+	seen := map[string]bool{}
+	repeated := 0
+	for _, line := range lines {
+		key := strings.Join(lyricWords(line), " ")
+		if seen[key] {
+			repeated++
+		}
+		seen[key] = true
+	}
+	if len(lines) > 0 {
+		analysis.RepeatedLineRatio = math.Round(float64(repeated)/float64(len(lines))*1000) / 1000
+	}
+
+	analysis.TopWords = topWords(wordCounts(words, primary), limit)
+
+	for _, section := range sections {
+		// A repeated chorus rhymes like the first one; counting it again would skew the patterns.
+		if section.Repeat || len(section.Lines) < 2 {
+			continue
+		}
+		scheme, pattern := rhymeScheme(section.Lines, primary)
+		analysis.Verses = append(analysis.Verses, VerseRhyme{
+			Index: section.Index, Type: section.Type, Label: section.Label, Scheme: scheme, Pattern: pattern,
+		})
+	}
+	return analysis, words
+}
+
+// topWordsParam reads the top query parameter, writing a 400 response if it is invalid.
+func topWordsParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("top")
+	if value == "" {
+		return defaultTopWords, true
+	}
+	top, err := strconv.Atoi(value)
+	if err != nil || top < 1 || top > maxTopWords {
+		slog.Warn("Invalid top parameter", "top", value)
+		http.Error(w, "Invalid 'top' parameter, expected 1 to 100", http.StatusBadRequest)
+		return 0, false
+	}
+	return top, true
+}
+
+// @Summary Lyrics analysis
+// @Description Get statistics of a song's lyrics: word count, unique words and lexical density, the most frequent words without stop words (English and Russian lists), the share of repeated lines, the rhyme scheme of every section (AABB couplet, ABAB alternate, ABBA enclosed, monorhyme or free) and the Flesch reading ease, with Oborneva's coefficients for Russian. The language is the song's own or is guessed from the alphabet.
+// @Tags analysis
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param top query int false "Number of frequent words (default 10, at most 100)"
+// @Success 200 {object} LyricsAnalysis "Lyrics statistics"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to analyze lyrics"
+// @Router /songs/{id_song}/analysis [get]
+func getLyricsAnalysis(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getLyricsAnalysis")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	top, ok := topWordsParam(w, r)
+	if !ok {
+		return
+	}
+
+	var song struct {
+		Lyrics   string  `db:"lyrics"`
+		Language *string `db:"language"`
+	}
+	if err := db.Get(&song, "SELECT COALESCE(lyrics, '') AS lyrics, language FROM songs WHERE id_song = $1", idSong); err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to analyze lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	sections, err := lyricSections(idSong, song.Lyrics)
+	if err != nil {
+		slog.Error("Failed to fetch lyric sections", "error", err)
+		http.Error(w, "Failed to analyze lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	language := ""
+	if song.Language != nil {
+		language = *song.Language
+	}
+	analysis, _ := analyzeLyrics(sections, language, top)
+	analysis.SongID = idSong
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analysis)
+}
+
+// @Summary Group lyrics analysis
+// @Description Aggregate the lyrics statistics of all songs of a group that have lyrics: total and unique words across them, the most frequent words overall, averages of the repeated-line share and reading ease, and how many sections use each rhyme pattern.
+// @Tags analysis
+// @Produce  json
+// @Param id_group path int true "ID of the group"
+// @Param top query int false "Number of frequent words (default 10, at most 100)"
+// @Success 200 {object} GroupAnalysis "Aggregated statistics"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Group not found"
+// @Failure 500 {string} string "Failed to analyze lyrics"
+// @Router /groups/{id_group}/analysis [get]
+func getGroupAnalysis(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getGroupAnalysis")
+
+	idGroup, ok := pathID(w, r, "id_group")
+	if !ok {
+		return
+	}
+	top, ok := topWordsParam(w, r)
+	if !ok {
+		return
+	}
+
+	var group Group
+	if err := db.Get(&group, `SELECT id_group, groupName AS "groupName" FROM musicGroups WHERE id_group = $1`, idGroup); err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Group not found", "id_group", idGroup)
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch group", "error", err)
+		http.Error(w, "Failed to analyze lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	var songs []struct {
+		ID       int     `db:"id_song"`
+		Lyrics   string  `db:"lyrics"`
+		Language *string `db:"language"`
+	}
+	err := db.Select(&songs, `
+		SELECT id_song, lyrics, language FROM songs
+		WHERE id_group = $1 AND COALESCE(lyrics, '') <> ''
+		ORDER BY id_song`, idGroup)
+	if err != nil {
+		slog.Error("Failed to fetch songs", "error", err)
+		http.Error(w, "Failed to analyze lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	result := GroupAnalysis{GroupID: idGroup, GroupName: group.GroupName, TopWords: []WordCount{}, RhymePatterns: map[string]int{}}
+	counts := map[string]int{}
+	unique := map[string]bool{}
+	var repeatedSum, readabilitySum float64
+	for _, song := range songs {
+		sections, err := lyricSections(song.ID, song.Lyrics)
+		if err != nil {
+			slog.Error("Failed to fetch lyric sections", "error", err)
+			http.Error(w, "Failed to analyze lyrics", http.StatusInternalServerError)
+			return
+		}
+		language := ""
+		if song.Language != nil {
+			language = *song.Language
+		}
+		analysis, words := analyzeLyrics(sections, language, top)
+
+		result.Songs++
+		result.WordCount += analysis.WordCount
+		for _, word := range words {
+			unique[word] = true
+		}
+		for word, count := range wordCounts(words, analysis.Language) {
+			counts[word] += count
+		}
+		for _, verse := range analysis.Verses {
+			result.RhymePatterns[verse.Pattern]++
+		}
+		repeatedSum += analysis.RepeatedLineRatio
+		readabilitySum += analysis.Readability
+	}
+
+	result.UniqueWords = len(unique)
+	result.TopWords = topWords(counts, top)
+	if result.Songs > 0 {
+		result.AvgRepeatedLineRatio = math.Round(repeatedSum/float64(result.Songs)*1000) / 1000
+		result.AvgReadability = math.Round(readabilitySum/float64(result.Songs)*10) / 10
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
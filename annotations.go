@@ -0,0 +1,562 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxAnnotationLength bounds the body of an annotation.
+const maxAnnotationLength = 10000
+
+const annotationColumnsSQL = `id_annotation, id_song, start_line, end_line, start_char, end_char, quote, body, author,
+	orphaned, created_at, updated_at`
+
+// annotationQuote checks an anchor against the lines of the lyrics and returns the text it covers.
+// Lines are numbered from 1, blank ones included; a character span, in runes, starts at start_char of
+// the first line and ends before end_char of the last.
+func annotationQuote(lines []string, a Annotation) (string, error) {
+	if a.StartLine < 1 || a.EndLine < a.StartLine || a.EndLine > len(lines) {
+		return "", fmt.Errorf("line range %d-%d is outside the lyrics (%d lines)", a.StartLine, a.EndLine, len(lines))
+	}
+	if (a.StartChar == nil) != (a.EndChar == nil) {
+		return "", errors.New("start_char and end_char go together")
+	}
+
+	covered := append([]string(nil), lines[a.StartLine-1:a.EndLine]...)
+	if a.StartChar != nil {
+		first, last := []rune(covered[0]), []rune(covered[len(covered)-1])
+		if *a.StartChar < 0 || *a.StartChar > len(first) || *a.EndChar < 0 || *a.EndChar > len(last) ||
+			(a.StartLine == a.EndLine && *a.EndChar <= *a.StartChar) {
+			return "", fmt.Errorf("character span %d-%d is outside lines %d-%d", *a.StartChar, *a.EndChar, a.StartLine, a.EndLine)
+		}
+		if len(covered) == 1 {
+			covered[0] = string(first[*a.StartChar:*a.EndChar])
+		} else {
+			covered[0] = string(first[*a.StartChar:])
+			covered[len(covered)-1] = string(last[:*a.EndChar])
+		}
+	}
+
+	quote := strings.Join(covered, "\n")
+	if strings.TrimSpace(quote) == "" {
+		return "", errors.New("the anchor covers no text")
+	}
+	return quote, nil
+}
+
+// relocateAnnotation moves an anchor from the old lyrics to the new ones. An anchor whose lines all
+// survived the edit in one piece just follows them. Otherwise the quoted text is looked for in the new
+// lyrics, nearest to where the anchor was, and a line-range anchor must still cover whole lines.
+// It reports false when the quote is gone and the annotation is orphaned.
+func relocateAnnotation(newLines []string, lineMap map[int]int, a Annotation) (Annotation, bool) {
+	if start, ok := lineMap[a.StartLine]; ok {
+		intact := true
+		for line := a.StartLine + 1; line <= a.EndLine; line++ {
+			if lineMap[line] != start+line-a.StartLine {
+				intact = false
+				break
+			}
+		}
+		if intact {
+			a.EndLine = start + a.EndLine - a.StartLine
+			a.StartLine = start
+			return a, true
+		}
+	}
+
+	text := strings.Join(newLines, "\n")
+	lineStarts := []int{0}
+	for i, c := range text {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	// position converts a byte offset of text to a line number and a rune column.
+	position := func(offset int) (int, int) {
+		line := 0
+		for line+1 < len(lineStarts) && lineStarts[line+1] <= offset {
+			line++
+		}
+		return line + 1, utf8.RuneCountInString(text[lineStarts[line]:offset])
+	}
+
+	var best *Annotation
+	bestDistance := 0
+	for from := 0; from <= len(text); {
+		index := strings.Index(text[from:], a.Quote)
+		if index < 0 {
+			break
+		}
+		start := from + index
+		from = start + 1
+
+		candidate := a
+		startLine, startChar := position(start)
+		endLine, endChar := position(start + len(a.Quote))
+		candidate.StartLine, candidate.EndLine = startLine, endLine
+		if a.StartChar != nil {
+			candidate.StartChar, candidate.EndChar = &startChar, &endChar
+		} else if startChar != 0 || endChar != utf8.RuneCountInString(newLines[endLine-1]) {
+			continue
+		}
+
+		distance := max(startLine-a.StartLine, a.StartLine-startLine)
+		if best == nil || distance < bestDistance {
+			best, bestDistance = &candidate, distance
+		}
+	}
+	if best == nil {
+		return a, false
+	}
+	return *best, true
+}
+
+// reanchorAnnotations moves the annotations of a song after its lyrics changed from oldLyrics to
+// newLyrics, orphaning those whose text is gone. Orphaned annotations keep their last anchor, and
+// every later edit looks for their quote again, anchoring them where it comes back.
+func reanchorAnnotations(tx *sqlx.Tx, idSong int, oldLyrics, newLyrics string) error {
+	var annotations []Annotation
+	query := "SELECT " + annotationColumnsSQL + " FROM annotations WHERE id_song = $1 FOR UPDATE"
+	if err := tx.Select(&annotations, query, idSong); err != nil {
+		return err
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	newLines := textLines(newLyrics)
+	lineMap := map[int]int{}
+	for _, line := range diffLines(textLines(oldLyrics), newLines) {
+		if line.Op == diffEqual {
+			lineMap[line.OldLine] = line.NewLine
+		}
+	}
+
+	orphaned, restored := 0, 0
+	for _, annotation := range annotations {
+		// The anchor of an orphaned annotation points into older lyrics, so only its quote can place it.
+		lines := lineMap
+		if annotation.Orphaned {
+			lines = nil
+		}
+		moved, ok := relocateAnnotation(newLines, lines, annotation)
+		if !ok {
+			if annotation.Orphaned {
+				continue
+			}
+			orphaned++
+			if _, err := tx.Exec("UPDATE annotations SET orphaned = TRUE WHERE id_annotation = $1", annotation.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if annotation.Orphaned {
+			restored++
+		}
+		_, err := tx.Exec(`
+			UPDATE annotations SET start_line = $2, end_line = $3, start_char = $4, end_char = $5, orphaned = FALSE
+			WHERE id_annotation = $1`,
+			annotation.ID, moved.StartLine, moved.EndLine, moved.StartChar, moved.EndChar)
+		if err != nil {
+			return err
+		}
+	}
+	if orphaned > 0 {
+		slog.Warn("Lyrics edit orphaned annotations", "id_song", idSong, "orphaned", orphaned, "annotations", len(annotations))
+	}
+	if restored > 0 {
+		slog.Info("Lyrics edit anchored orphaned annotations again", "id_song", idSong, "restored", restored)
+	}
+	return nil
+}
+
+// annotationFromPath reads the {id_annotation} path variable, writing a 400 response if it is invalid.
+func annotationFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	return pathID(w, r, "id_annotation")
+}
+
+// songLines returns the lines of a song's lyrics; sql.ErrNoRows means there is no such song.
+func songLines(q sqlx.Queryer, idSong int) ([]string, error) {
+	var lyrics string
+	if err := sqlx.Get(q, &lyrics, "SELECT COALESCE(lyrics, '') FROM songs WHERE id_song = $1", idSong); err != nil {
+		return nil, err
+	}
+	return textLines(lyrics), nil
+}
+
+// @Summary List annotations
+// @Description Get the annotations of a song ordered by position. Use orphaned to get only those whose text was lost in an edit (true) or only anchored ones (false).
+// @Tags annotations
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param orphaned query bool false "Filter by orphaned flag"
+// @Success 200 {array} Annotation "Annotations of the song"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch annotations"
+// @Router /songs/{id_song}/annotations [get]
+func getAnnotations(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getAnnotations")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	query := "SELECT " + annotationColumnsSQL + " FROM annotations WHERE id_song = $1"
+	args := []interface{}{idSong}
+	if value := r.URL.Query().Get("orphaned"); value != "" {
+		orphaned, err := strconv.ParseBool(value)
+		if err != nil {
+			slog.Warn("Invalid orphaned parameter", "orphaned", value)
+			http.Error(w, "Invalid 'orphaned' parameter", http.StatusBadRequest)
+			return
+		}
+		query += " AND orphaned = $2"
+		args = append(args, orphaned)
+	}
+	query += " ORDER BY start_line, start_char NULLS FIRST, id_annotation"
+
+	if _, err := songLines(db, idSong); err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to fetch annotations", http.StatusInternalServerError)
+		return
+	}
+
+	annotations := []Annotation{}
+	if err := db.Select(&annotations, query, args...); err != nil {
+		slog.Error("Failed to fetch annotations", "error", err)
+		http.Error(w, "Failed to fetch annotations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(annotations)
+}
+
+// @Summary Get an annotation
+// @Description Get one annotation of a song.
+// @Tags annotations
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param id_annotation path int true "ID of the annotation"
+// @Success 200 {object} Annotation "The annotation"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Annotation not found"
+// @Failure 500 {string} string "Failed to fetch annotation"
+// @Router /songs/{id_song}/annotations/{id_annotation} [get]
+func getAnnotation(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getAnnotation")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	idAnnotation, ok := annotationFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var annotation Annotation
+	query := "SELECT " + annotationColumnsSQL + " FROM annotations WHERE id_song = $1 AND id_annotation = $2"
+	if err := db.Get(&annotation, query, idSong, idAnnotation); err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Annotation not found", "id_song", idSong, "id_annotation", idAnnotation)
+			http.Error(w, "Annotation not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch annotation", "error", err)
+		http.Error(w, "Failed to fetch annotation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(annotation)
+}
+
+// decodeAnnotation reads an annotation from the request body and checks it against the song's lyrics,
+// filling in its quote. It writes the error response itself.
+func decodeAnnotation(w http.ResponseWriter, r *http.Request, tx *sqlx.Tx, idSong int) (Annotation, bool) {
+	var annotation Annotation
+	if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+		slog.Warn("Invalid request body", "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return annotation, false
+	}
+	annotation.Body = strings.TrimSpace(annotation.Body)
+	if annotation.Body == "" || utf8.RuneCountInString(annotation.Body) > maxAnnotationLength {
+		slog.Warn("Invalid annotation body", "id_song", idSong)
+		http.Error(w, fmt.Sprintf("Annotation body must be 1 to %d characters", maxAnnotationLength), http.StatusBadRequest)
+		return annotation, false
+	}
+
+	lines, err := songLines(tx, idSong)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return annotation, false
+		}
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to store annotation", http.StatusInternalServerError)
+		return annotation, false
+	}
+	if annotation.Quote, err = annotationQuote(lines, annotation); err != nil {
+		slog.Warn("Invalid annotation anchor", "id_song", idSong, "error", err)
+		http.Error(w, "Invalid anchor: "+err.Error(), http.StatusBadRequest)
+		return annotation, false
+	}
+	return annotation, true
+}
+
+// @Summary Annotate lyrics
+// @Description Attach a note to a line range of a song's lyrics, or to a character span within it when start_char and end_char are given. Lines are numbered from 1 as in the stored lyrics, blank lines included, and characters from 0. When the lyrics are edited the annotation follows its text or, if the text is gone, is flagged as orphaned until a later edit brings the text back.
+// @Tags annotations
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param input body Annotation true "Anchor (start_line, end_line, optional start_char and end_char) and body"
+// @Param X-Author header string false "Who writes the annotation (default anonymous)"
+// @Success 201 {object} Annotation "The created annotation"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to store annotation"
+// @Router /songs/{id_song}/annotations [post]
+func addAnnotation(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request addAnnotation")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		http.Error(w, "Failed to store annotation", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the song so the lyrics can't change between the check and the insert.
+	if _, err := tx.Exec("SELECT 1 FROM songs WHERE id_song = $1 FOR SHARE", idSong); err != nil {
+		slog.Error("Failed to lock song", "error", err)
+		http.Error(w, "Failed to store annotation", http.StatusInternalServerError)
+		return
+	}
+	annotation, ok := decodeAnnotation(w, r, tx, idSong)
+	if !ok {
+		return
+	}
+
+	query := `
+		INSERT INTO annotations (id_song, start_line, end_line, start_char, end_char, quote, body, author)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + annotationColumnsSQL
+	err = tx.Get(&annotation, query, idSong, annotation.StartLine, annotation.EndLine, annotation.StartChar,
+		annotation.EndChar, annotation.Quote, annotation.Body, revisionAuthor(r))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.Error("Failed to store annotation", "error", err)
+		http.Error(w, "Failed to store annotation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(annotation)
+
+	slog.Debug("Annotation added", "id_song", idSong, "id_annotation", annotation.ID)
+}
+
+// @Summary Update an annotation
+// @Description Replace the anchor and body of an annotation. Anchoring an orphaned annotation again clears its orphaned flag.
+// @Tags annotations
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param id_annotation path int true "ID of the annotation"
+// @Param input body Annotation true "Anchor (start_line, end_line, optional start_char and end_char) and body"
+// @Success 200 {object} Annotation "The updated annotation"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Annotation not found"
+// @Failure 500 {string} string "Failed to store annotation"
+// @Router /songs/{id_song}/annotations/{id_annotation} [put]
+func updateAnnotation(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request updateAnnotation")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	idAnnotation, ok := annotationFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		http.Error(w, "Failed to store annotation", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT 1 FROM songs WHERE id_song = $1 FOR SHARE", idSong); err != nil {
+		slog.Error("Failed to lock song", "error", err)
+		http.Error(w, "Failed to store annotation", http.StatusInternalServerError)
+		return
+	}
+	annotation, ok := decodeAnnotation(w, r, tx, idSong)
+	if !ok {
+		return
+	}
+
+	query := `
+		UPDATE annotations
+		SET start_line = $3, end_line = $4, start_char = $5, end_char = $6, quote = $7, body = $8,
+			orphaned = FALSE, updated_at = now()
+		WHERE id_song = $1 AND id_annotation = $2
+		RETURNING ` + annotationColumnsSQL
+	err = tx.Get(&annotation, query, idSong, idAnnotation, annotation.StartLine, annotation.EndLine,
+		annotation.StartChar, annotation.EndChar, annotation.Quote, annotation.Body)
+	if err == sql.ErrNoRows {
+		slog.Warn("Annotation not found", "id_song", idSong, "id_annotation", idAnnotation)
+		http.Error(w, "Annotation not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.Error("Failed to store annotation", "error", err)
+		http.Error(w, "Failed to store annotation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(annotation)
+
+	slog.Debug("Annotation updated", "id_song", idSong, "id_annotation", idAnnotation)
+}
+
+// @Summary Delete an annotation
+// @Description Remove an annotation from a song.
+// @Tags annotations
+// @Param id_song path int true "ID of the song"
+// @Param id_annotation path int true "ID of the annotation"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Annotation not found"
+// @Failure 500 {string} string "Failed to delete annotation"
+// @Router /songs/{id_song}/annotations/{id_annotation} [delete]
+func deleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request deleteAnnotation")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	idAnnotation, ok := annotationFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM annotations WHERE id_song = $1 AND id_annotation = $2", idSong, idAnnotation)
+	if err != nil {
+		slog.Error("Failed to delete annotation", "error", err)
+		http.Error(w, "Failed to delete annotation", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		slog.Warn("Annotation not found", "id_song", idSong, "id_annotation", idAnnotation)
+		http.Error(w, "Annotation not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Annotated lyrics
+// @Description Get the lyrics of a song line by line, each line with the anchors of the annotations covering it: the annotation ID and, for character spans, the covered columns of that line. Orphaned annotations are counted but not anchored.
+// @Tags annotations
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Success 200 {object} AnnotatedLyrics "Lyrics with annotation anchors"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch annotations"
+// @Router /songs/{id_song}/lyrics/annotated [get]
+func getAnnotatedLyrics(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getAnnotatedLyrics")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	lines, err := songLines(db, idSong)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to fetch annotations", http.StatusInternalServerError)
+		return
+	}
+
+	annotations := []Annotation{}
+	query := "SELECT " + annotationColumnsSQL + " FROM annotations WHERE id_song = $1 ORDER BY start_line, start_char NULLS FIRST, id_annotation"
+	if err := db.Select(&annotations, query, idSong); err != nil {
+		slog.Error("Failed to fetch annotations", "error", err)
+		http.Error(w, "Failed to fetch annotations", http.StatusInternalServerError)
+		return
+	}
+
+	result := AnnotatedLyrics{SongID: idSong, Lines: make([]AnnotatedLine, len(lines)), Annotations: []Annotation{}}
+	for i, line := range lines {
+		result.Lines[i] = AnnotatedLine{Line: i + 1, Text: line, Anchors: []AnnotationAnchor{}}
+	}
+	for _, annotation := range annotations {
+		if annotation.Orphaned || annotation.EndLine > len(lines) {
+			result.Orphaned++
+			continue
+		}
+		result.Annotations = append(result.Annotations, annotation)
+		for number := annotation.StartLine; number <= annotation.EndLine; number++ {
+			anchor := AnnotationAnchor{AnnotationID: annotation.ID}
+			if annotation.StartChar != nil {
+				start, end := 0, utf8.RuneCountInString(lines[number-1])
+				if number == annotation.StartLine {
+					start = *annotation.StartChar
+				}
+				if number == annotation.EndLine {
+					end = *annotation.EndChar
+				}
+				anchor.StartChar, anchor.EndChar = &start, &end
+			}
+			result.Lines[number-1].Anchors = append(result.Lines[number-1].Anchors, anchor)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
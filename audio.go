@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxDuration = 24 * 60 * 60
+	minBPM      = 20
+	maxBPM      = 400
+)
+
+// audioMetadataColumnsSQL selects every AudioMetadata field from songs s.
+const audioMetadataColumnsSQL = `s.duration, s.bpm, s.musical_key, s.time_signature, s.explicit`
+
+var (
+	keyPattern           = regexp.MustCompile(`^([A-Ga-g])([#b♯♭]?)\s*(m|min|minor|maj|major)?$`)
+	timeSignaturePattern = regexp.MustCompile(`^([0-9]{1,2})/([0-9]{1,2})$`)
+)
+
+// normalizeKey turns spellings like "c# minor" or "Bb maj" into "C#m" or "Bb".
+func normalizeKey(key string) (string, error) {
+	match := keyPattern.FindStringSubmatch(strings.TrimSpace(key))
+	if match == nil {
+		return "", fmt.Errorf("invalid musical key %q, expected e.g. C, F#, Bbm", key)
+	}
+
+	normalized := strings.ToUpper(match[1])
+	switch match[2] {
+	case "#", "♯":
+		normalized += "#"
+	case "b", "♭":
+		normalized += "b"
+	}
+	switch match[3] {
+	case "m", "min", "minor":
+		normalized += "m"
+	}
+	return normalized, nil
+}
+
+// normalizeTimeSignature validates signatures like 4/4 or 7/8; the lower number must be a power of two.
+func normalizeTimeSignature(signature string) (string, error) {
+	match := timeSignaturePattern.FindStringSubmatch(strings.ReplaceAll(signature, " ", ""))
+	if match == nil {
+		return "", fmt.Errorf("invalid time signature %q, expected e.g. 4/4", signature)
+	}
+
+	beats, _ := strconv.Atoi(match[1])
+	unit, _ := strconv.Atoi(match[2])
+	if beats < 1 || unit < 1 || unit > 32 || unit&(unit-1) != 0 {
+		return "", fmt.Errorf("invalid time signature %q, expected e.g. 4/4", signature)
+	}
+	return fmt.Sprintf("%d/%d", beats, unit), nil
+}
+
+// validate checks the fields that are set and normalizes BPM, key and time signature in place.
+// BPM is rounded to the two decimals songs.bpm stores, so fetched values compare equal to stored ones.
+func (m *AudioMetadata) validate() error {
+	if m.Duration != nil && (*m.Duration < 1 || *m.Duration > maxDuration) {
+		return fmt.Errorf("duration must be between 1 and %d seconds", maxDuration)
+	}
+	if m.BPM != nil {
+		bpm := math.Round(*m.BPM*100) / 100
+		m.BPM = &bpm
+	}
+	if m.BPM != nil && (*m.BPM < minBPM || *m.BPM > maxBPM) {
+		return fmt.Errorf("bpm must be between %d and %d", minBPM, maxBPM)
+	}
+	if m.Key != nil {
+		key, err := normalizeKey(*m.Key)
+		if err != nil {
+			return err
+		}
+		m.Key = &key
+	}
+	if m.TimeSignature != nil {
+		signature, err := normalizeTimeSignature(*m.TimeSignature)
+		if err != nil {
+			return err
+		}
+		m.TimeSignature = &signature
+	}
+	return nil
+}
+
+func parseFloatParam(value string) (interface{}, error) {
+	return strconv.ParseFloat(value, 64)
+}
+
+func parseIntParam(value string) (interface{}, error) {
+	return strconv.Atoi(value)
+}
+
+func parseBoolParam(value string) (interface{}, error) {
+	return strconv.ParseBool(value)
+}
+
+func parseKeyParam(value string) (interface{}, error) {
+	return normalizeKey(value)
+}
+
+func parseTimeSignatureParam(value string) (interface{}, error) {
+	return normalizeTimeSignature(value)
+}
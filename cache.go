@@ -0,0 +1,274 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// detailCache stores provider answers by key. Implementations must be safe for concurrent use;
+// a shared implementation lets several instances of the service reuse each other's lookups.
+type detailCache interface {
+	Get(key string) (cachedDetail, bool)
+	Set(key string, entry cachedDetail, ttl time.Duration)
+	Len() int
+}
+
+// cachedDetail is a provider answer: details, or the fact that the provider doesn't know the song.
+type cachedDetail struct {
+	Detail   SongDetail `json:"detail"`
+	Problems []string   `json:"problems,omitempty"`
+	NotFound bool       `json:"not_found"`
+}
+
+// cacheStats counts lookups of the response cache.
+type cacheStats struct {
+	hits         atomic.Int64
+	negativeHits atomic.Int64
+	misses       atomic.Int64
+	bypasses     atomic.Int64
+}
+
+// detailCacheStats is shared by every cachingProvider so the metrics cover all providers.
+var detailCacheStats cacheStats
+
+// sharedDetailCache is the cache behind every cachingProvider; nil when caching is disabled.
+var sharedDetailCache detailCache
+
+type cacheBypassKey struct{}
+
+// bypassCache makes lookups with the returned context go to the provider. The answer still
+// replaces the cached one, so a refresh also refreshes the cache.
+func bypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// cachingProvider answers repeated lookups of the same group and song from a cache. Found details
+// are kept for ttl, "not found" answers for the usually shorter negativeTTL; errors aren't cached.
+type cachingProvider struct {
+	provider    detailProvider
+	cache       detailCache
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+// newDetailCacheFromEnv builds the cache selected by ENRICHMENT_CACHE: memory (default), postgres or none.
+func newDetailCacheFromEnv() (detailCache, error) {
+	switch kind := os.Getenv("ENRICHMENT_CACHE"); kind {
+	case "", "memory":
+		return newLRUCache(envInt("ENRICHMENT_CACHE_SIZE", 10000)), nil
+	case "postgres":
+		if db == nil {
+			return nil, fmt.Errorf("postgres cache needs a database connection")
+		}
+		return &postgresCache{}, nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown cache %q, expected memory, postgres or none", kind)
+	}
+}
+
+// withCache wraps provider in the shared cache, if there is one.
+func withCache(provider detailProvider) detailProvider {
+	if sharedDetailCache == nil {
+		return provider
+	}
+	return &cachingProvider{
+		provider:    provider,
+		cache:       sharedDetailCache,
+		ttl:         envDuration("ENRICHMENT_CACHE_TTL", 24*time.Hour),
+		negativeTTL: envDuration("ENRICHMENT_CACHE_NEGATIVE_TTL", 10*time.Minute),
+	}
+}
+
+func (p *cachingProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *cachingProvider) Fetch(ctx context.Context, group, song string) (SongDetail, error) {
+	key := p.provider.Name() + "\x00" + normalizeSongKey(group, song)
+
+	if bypass, _ := ctx.Value(cacheBypassKey{}).(bool); bypass {
+		detailCacheStats.bypasses.Add(1)
+	} else if entry, ok := p.cache.Get(key); ok {
+		if entry.NotFound {
+			detailCacheStats.negativeHits.Add(1)
+			return SongDetail{}, errSongDetailsNotFound
+		}
+		detailCacheStats.hits.Add(1)
+		entry.Detail.Problems = entry.Problems
+		return entry.Detail, nil
+	} else {
+		detailCacheStats.misses.Add(1)
+	}
+
+	detail, err := p.provider.Fetch(ctx, group, song)
+	switch {
+	case err == nil:
+		p.cache.Set(key, cachedDetail{Detail: detail, Problems: detail.Problems}, p.ttl)
+	case errors.Is(err, errSongDetailsNotFound):
+		p.cache.Set(key, cachedDetail{NotFound: true}, p.negativeTTL)
+	}
+	return detail, err
+}
+
+// lruCache is an in-memory cache dropping the least recently used entry once it holds capacity entries.
+type lruCache struct {
+	mu        sync.Mutex
+	capacity  int
+	entries   map[string]*list.Element
+	order     *list.List
+	evictions atomic.Int64
+}
+
+type lruEntry struct {
+	key       string
+	value     cachedDetail
+	expiresAt time.Time
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *lruCache) Get(key string) (cachedDetail, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return cachedDetail{}, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return cachedDetail{}, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *lruCache) Set(key string, value cachedDetail, ttl time.Duration) {
+	if c.capacity < 1 || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *lruCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// postgresCache keeps answers in the enrichment_cache table so every instance shares them.
+// Expired rows are purged at most once per purgeInterval.
+type postgresCache struct {
+	lastPurge atomic.Int64
+}
+
+const purgeInterval = 10 * time.Minute
+
+func (c *postgresCache) Get(key string) (cachedDetail, bool) {
+	var data []byte
+	err := db.Get(&data, "SELECT entry FROM enrichment_cache WHERE key = $1 AND expires_at > now()", key)
+	if err != nil {
+		return cachedDetail{}, false
+	}
+	var entry cachedDetail
+	if err := json.Unmarshal(data, &entry); err != nil {
+		slog.Warn("Dropping malformed cache entry", "key", key, "error", err)
+		return cachedDetail{}, false
+	}
+	return entry, true
+}
+
+func (c *postgresCache) Set(key string, entry cachedDetail, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_, err = db.Exec(`
+		INSERT INTO enrichment_cache (key, entry, expires_at) VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (key) DO UPDATE SET entry = EXCLUDED.entry, expires_at = EXCLUDED.expires_at`,
+		key, data, ttl.Milliseconds())
+	if err != nil {
+		slog.Warn("Failed to store cache entry", "key", key, "error", err)
+	}
+
+	now := time.Now().UnixNano()
+	if last := c.lastPurge.Load(); now-last > int64(purgeInterval) && c.lastPurge.CompareAndSwap(last, now) {
+		if _, err := db.Exec("DELETE FROM enrichment_cache WHERE expires_at <= now()"); err != nil {
+			slog.Warn("Failed to purge expired cache entries", "error", err)
+		}
+	}
+}
+
+func (c *postgresCache) Len() int {
+	var count int
+	if err := db.Get(&count, "SELECT COUNT(*) FROM enrichment_cache WHERE expires_at > now()"); err != nil {
+		return -1
+	}
+	return count
+}
+
+// @Summary Response cache metrics
+// @Description Get hit and miss counters of the cache in front of the enrichment providers since startup. Negative hits are cached "not found" answers; bypasses are lookups made by refreshes.
+// @Tags enrichment
+// @Produce  json
+// @Success 200 {object} CacheStats "Cache metrics"
+// @Router /enrichment/cache [get]
+func getCacheStats(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getCacheStats")
+
+	stats := CacheStats{
+		Enabled:      sharedDetailCache != nil,
+		Hits:         detailCacheStats.hits.Load(),
+		NegativeHits: detailCacheStats.negativeHits.Load(),
+		Misses:       detailCacheStats.misses.Load(),
+		Bypasses:     detailCacheStats.bypasses.Load(),
+	}
+	if sharedDetailCache != nil {
+		stats.Entries = sharedDetailCache.Len()
+	}
+	if lru, ok := sharedDetailCache.(*lruCache); ok {
+		stats.Evictions = lru.evictions.Load()
+	}
+	if lookups := stats.Hits + stats.NegativeHits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits+stats.NegativeHits) / float64(lookups)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// maxChordSheetSize bounds an uploaded ChordPro sheet.
+const maxChordSheetSize = 256 << 10
+
+// Accidentals a transposed chord can be spelled with.
+const (
+	accidentalsSharp = "sharp"
+	accidentalsFlat  = "flat"
+)
+
+// chordProDirectives maps the ChordPro directives we understand, short forms included, to their full name.
+var chordProDirectives = map[string]string{
+	"title": "title", "t": "title",
+	"subtitle": "subtitle", "st": "subtitle",
+	"artist": "artist", "composer": "composer", "lyricist": "lyricist", "album": "album", "year": "year",
+	"key": "key", "capo": "capo", "tempo": "tempo", "time": "time", "duration": "duration", "meta": "meta",
+	"comment": "comment", "c": "comment",
+	"comment_italic": "comment_italic", "ci": "comment_italic",
+	"comment_box": "comment_box", "cb": "comment_box",
+	"highlight": "highlight", "chorus": "chorus",
+	"start_of_chorus": "start_of_chorus", "soc": "start_of_chorus",
+	"end_of_chorus": "end_of_chorus", "eoc": "end_of_chorus",
+	"start_of_verse": "start_of_verse", "sov": "start_of_verse",
+	"end_of_verse": "end_of_verse", "eov": "end_of_verse",
+	"start_of_bridge": "start_of_bridge", "sob": "start_of_bridge",
+	"end_of_bridge": "end_of_bridge", "eob": "end_of_bridge",
+	"start_of_tab": "start_of_tab", "sot": "start_of_tab",
+	"end_of_tab": "end_of_tab", "eot": "end_of_tab",
+	"new_page": "new_page", "np": "new_page",
+	"define": "define", "chord": "chord",
+}
+
+var (
+	// chordProDirectiveRe matches {name}, {name: value} and {name value}.
+	chordProDirectiveRe = regexp.MustCompile(`^\{\s*([a-zA-Z_]+)(?:\s*[:\s]\s*(.*?))?\s*\}$`)
+	// chordRe splits a chord into root, accidental, quality and an optional bass note, as in "F#m7/C#".
+	chordRe = regexp.MustCompile(`^([A-G])([#b]?)([^/]*)(?:/([A-G])([#b]?))?$`)
+	// chordQualityRe lists what may follow the root: m7, maj7, sus4, add9, dim, aug, 7(b9) and the like.
+	chordQualityRe = regexp.MustCompile(`^(?:maj|min|mi|m|M|dim|aug|sus|add|no|alt|[0-9]|\+|-|°|ø|Δ|#|b|\(|\)|,)*$`)
+)
+
+var (
+	sharpNotes = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	flatNotes  = [12]string{"C", "Db", "D", "Eb", "E", "F", "Gb", "G", "Ab", "A", "Bb", "B"}
+	noteIndex  = map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+	// flatKeys are the keys conventionally written with flats. Gb is left out as F# is as common.
+	flatKeys = map[string]bool{"F": true, "Bb": true, "Eb": true, "Ab": true, "Db": true,
+		"Dm": true, "Gm": true, "Cm": true, "Fm": true, "Bbm": true, "Ebm": true}
+)
+
+// chordProLine is one parsed line of a sheet.
+type chordProLine struct {
+	// directive is the full directive name, empty for lyrics.
+	directive string
+	value     string
+	lyrics    string
+	chords    []placedChord
+}
+
+// placedChord is a chord and the rune offset in the lyrics it is sung on.
+type placedChord struct {
+	at    int
+	chord string
+}
+
+// chordProSheet is a parsed ChordPro sheet.
+type chordProSheet struct {
+	lines []chordProLine
+	title string
+	// key is the first {key}, the key the song starts in; later ones are modulations.
+	key string
+}
+
+// isChord reports whether name is a chord we can transpose. "N.C." (no chord) and ChordPro
+// annotations like [*Riff] are allowed in sheets but are not chords.
+func isChord(name string) bool {
+	m := chordRe.FindStringSubmatch(name)
+	return m != nil && chordQualityRe.MatchString(m[3])
+}
+
+func noteOf(letter, accidental string) int {
+	n := noteIndex[letter]
+	switch accidental {
+	case "#":
+		n++
+	case "b":
+		n--
+	}
+	return (n + 12) % 12
+}
+
+// transposeChord moves a chord by semitones. accidentals picks sharps or flats; empty keeps the
+// chord's own spelling, sharps for natural notes.
+func transposeChord(name string, semitones int, accidentals string) string {
+	m := chordRe.FindStringSubmatch(name)
+	if m == nil || !chordQualityRe.MatchString(m[3]) {
+		return name
+	}
+	spell := func(letter, accidental string) string {
+		n := ((noteOf(letter, accidental)+semitones)%12 + 12) % 12
+		if accidentals == accidentalsFlat || (accidentals == "" && accidental == "b") {
+			return flatNotes[n]
+		}
+		return sharpNotes[n]
+	}
+
+	chord := spell(m[1], m[2]) + m[3]
+	if m[4] != "" {
+		chord += "/" + spell(m[4], m[5])
+	}
+	return chord
+}
+
+// keySemitones returns how many semitones lead from one key to another of the same mode.
+func keySemitones(from, to string) (int, error) {
+	fromMinor, toMinor := strings.HasSuffix(from, "m"), strings.HasSuffix(to, "m")
+	if fromMinor != toMinor {
+		return 0, fmt.Errorf("cannot transpose from %s to %s: keys must both be major or both minor", from, to)
+	}
+	a, b := chordRe.FindStringSubmatch(from), chordRe.FindStringSubmatch(to)
+	return ((noteOf(b[1], b[2])-noteOf(a[1], a[2]))%12 + 12) % 12, nil
+}
+
+// parseChordPro reads a ChordPro sheet, checking directives, chords and that sections are closed in order.
+func parseChordPro(text string) (chordProSheet, error) {
+	var sheet chordProSheet
+	var open []string
+
+	text = lineEndingRe.ReplaceAllString(text, "\n")
+	for number, raw := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		number++
+		line := strings.TrimRight(raw, " \t")
+		inTab := len(open) > 0 && open[len(open)-1] == "tab"
+
+		if strings.HasPrefix(strings.TrimSpace(line), "{") {
+			m := chordProDirectiveRe.FindStringSubmatch(strings.TrimSpace(line))
+			if m == nil {
+				return sheet, fmt.Errorf("line %d: malformed directive %q", number, line)
+			}
+			name, value := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+			directive, known := chordProDirectives[name]
+			if !known && !strings.HasPrefix(name, "x_") {
+				return sheet, fmt.Errorf("line %d: unknown directive {%s}", number, name)
+			}
+			if !known {
+				directive = name
+			}
+			if inTab && directive != "end_of_tab" {
+				return sheet, fmt.Errorf("line %d: directive {%s} inside a tab section", number, name)
+			}
+
+			switch {
+			case strings.HasPrefix(directive, "start_of_"):
+				open = append(open, strings.TrimPrefix(directive, "start_of_"))
+			case strings.HasPrefix(directive, "end_of_"):
+				section := strings.TrimPrefix(directive, "end_of_")
+				if len(open) == 0 || open[len(open)-1] != section {
+					return sheet, fmt.Errorf("line %d: {%s} without a matching {start_of_%s}", number, name, section)
+				}
+				open = open[:len(open)-1]
+			case directive == "title":
+				sheet.title = value
+			case directive == "key":
+				key, err := normalizeKey(value)
+				if err != nil {
+					return sheet, fmt.Errorf("line %d: %v", number, err)
+				}
+				if sheet.key == "" {
+					sheet.key = key
+				}
+				value = key
+			}
+			if (directive == "title" || directive == "key") && value == "" {
+				return sheet, fmt.Errorf("line %d: {%s} needs a value", number, name)
+			}
+			sheet.lines = append(sheet.lines, chordProLine{directive: directive, value: value})
+			continue
+		}
+
+		// Tab sections are kept verbatim.
+		if inTab {
+			sheet.lines = append(sheet.lines, chordProLine{lyrics: line})
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parsed := chordProLine{}
+		var lyrics strings.Builder
+		for rest := line; rest != ""; {
+			bracket := strings.IndexByte(rest, '[')
+			if bracket < 0 {
+				lyrics.WriteString(rest)
+				break
+			}
+			lyrics.WriteString(rest[:bracket])
+			end := strings.IndexByte(rest[bracket:], ']')
+			if end < 0 {
+				return sheet, fmt.Errorf("line %d: unclosed chord bracket", number)
+			}
+			name := strings.TrimSpace(rest[bracket+1 : bracket+end])
+			if name == "" || (!isChord(name) && name != "N.C." && !strings.HasPrefix(name, "*")) {
+				return sheet, fmt.Errorf("line %d: invalid chord [%s]", number, name)
+			}
+			parsed.chords = append(parsed.chords, placedChord{at: utf8.RuneCountInString(lyrics.String()), chord: name})
+			rest = rest[bracket+end+1:]
+		}
+		if strings.Contains(lyrics.String(), "]") {
+			return sheet, fmt.Errorf("line %d: unexpected ']'", number)
+		}
+		parsed.lyrics = lyrics.String()
+		sheet.lines = append(sheet.lines, parsed)
+	}
+
+	if len(open) > 0 {
+		return sheet, fmt.Errorf("{start_of_%s} is never closed", open[len(open)-1])
+	}
+	return sheet, nil
+}
+
+// transpose returns a copy of the sheet with every chord and the key moved by semitones.
+func (s chordProSheet) transpose(semitones int, accidentals string) chordProSheet {
+	out := chordProSheet{title: s.title, key: s.key, lines: make([]chordProLine, len(s.lines))}
+	if s.key != "" {
+		out.key = transposeChord(s.key, semitones, accidentals)
+	}
+	for i, line := range s.lines {
+		out.lines[i] = line
+		if line.directive == "key" {
+			out.lines[i].value = transposeChord(line.value, semitones, accidentals)
+		}
+		if len(line.chords) > 0 {
+			out.lines[i].chords = make([]placedChord, len(line.chords))
+			for j, chord := range line.chords {
+				out.lines[i].chords[j] = placedChord{at: chord.at, chord: transposeChord(chord.chord, semitones, accidentals)}
+			}
+		}
+	}
+	return out
+}
+
+// chordPro renders the sheet back to ChordPro; comments in the source are dropped.
+func (s chordProSheet) chordPro() string {
+	var b strings.Builder
+	for _, line := range s.lines {
+		switch {
+		case line.directive != "" && line.value != "":
+			fmt.Fprintf(&b, "{%s: %s}\n", line.directive, line.value)
+		case line.directive != "":
+			fmt.Fprintf(&b, "{%s}\n", line.directive)
+		default:
+			lyrics := []rune(line.lyrics)
+			last := 0
+			for _, chord := range line.chords {
+				b.WriteString(string(lyrics[last:chord.at]))
+				fmt.Fprintf(&b, "[%s]", chord.chord)
+				last = chord.at
+			}
+			b.WriteString(string(lyrics[last:]))
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// text renders the sheet as plain text with every chord written above the syllable it is sung on.
+// Lyrics are padded where chords would otherwise run into each other.
+func (s chordProSheet) text() string {
+	var b strings.Builder
+	for _, line := range s.lines {
+		switch line.directive {
+		case "":
+			if len(line.chords) == 0 {
+				b.WriteString(line.lyrics + "\n")
+				continue
+			}
+			chordRow, lyricRow := chordRows(line)
+			b.WriteString(chordRow + "\n")
+			if strings.TrimSpace(lyricRow) != "" {
+				b.WriteString(lyricRow + "\n")
+			}
+		case "title", "subtitle", "artist":
+			b.WriteString(line.value + "\n")
+		case "key":
+			b.WriteString("Key: " + line.value + "\n")
+		case "capo":
+			b.WriteString("Capo: " + line.value + "\n")
+		case "comment", "comment_italic", "comment_box", "highlight":
+			b.WriteString("(" + line.value + ")\n")
+		case "start_of_chorus", "start_of_verse", "start_of_bridge":
+			label := line.value
+			if label == "" {
+				label = strings.ToUpper(line.directive[9:10]) + line.directive[10:]
+			}
+			b.WriteString(label + ":\n")
+		case "chorus":
+			b.WriteString("Chorus\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// chordRows lays out a line as a row of chords over its lyrics.
+func chordRows(line chordProLine) (string, string) {
+	lyrics := []rune(line.lyrics)
+	var chordRow, lyricRow strings.Builder
+	lyricRow.WriteString(string(lyrics[:line.chords[0].at]))
+	chordRow.WriteString(strings.Repeat(" ", line.chords[0].at))
+
+	for i, chord := range line.chords {
+		end := len(lyrics)
+		if i+1 < len(line.chords) {
+			end = line.chords[i+1].at
+		}
+		segment := string(lyrics[chord.at:end])
+		segmentWidth := end - chord.at
+		chordWidth := utf8.RuneCountInString(chord.chord)
+		if i+1 < len(line.chords) && segmentWidth < chordWidth+1 {
+			segment += strings.Repeat(" ", chordWidth+1-segmentWidth)
+			segmentWidth = chordWidth + 1
+		}
+		chordRow.WriteString(chord.chord)
+		if i+1 < len(line.chords) {
+			chordRow.WriteString(strings.Repeat(" ", segmentWidth-chordWidth))
+		}
+		lyricRow.WriteString(segment)
+	}
+	return strings.TrimRight(chordRow.String(), " "), strings.TrimRight(lyricRow.String(), " ")
+}
+
+// chordNames lists the distinct chords of a sheet in order of first use.
+func (s chordProSheet) chordNames() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, line := range s.lines {
+		for _, chord := range line.chords {
+			if isChord(chord.chord) && !seen[chord.chord] {
+				seen[chord.chord] = true
+				names = append(names, chord.chord)
+			}
+		}
+	}
+	return names
+}
+
+// @Summary Upload a chord sheet
+// @Description Store a ChordPro sheet for a song, replacing any stored before. Directives must be known ChordPro ones (custom ones start with x_), chords must be valid, like [F#m7/C#], and start_of/end_of sections must match.
+// @Tags chords
+// @Accept  plain
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param input body string true "ChordPro sheet"
+// @Success 200 {object} ChordSheet "The stored sheet"
+// @Failure 400 {string} string "Invalid ChordPro"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to store chord sheet"
+// @Router /songs/{id_song}/chords [put]
+func putChordSheet(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request putChordSheet")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxChordSheetSize+1))
+	if err != nil || len(body) > maxChordSheetSize {
+		slog.Warn("Invalid chord sheet body", "error", err, "size", len(body))
+		http.Error(w, fmt.Sprintf("Chord sheet must be at most %d bytes", maxChordSheetSize), http.StatusBadRequest)
+		return
+	}
+
+	sheet, err := parseChordPro(string(body))
+	if err != nil {
+		slog.Warn("Invalid ChordPro", "id_song", idSong, "error", err)
+		http.Error(w, "Invalid ChordPro: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO chord_sheets (id_song, source) VALUES ($1, $2)
+		ON CONFLICT (id_song) DO UPDATE SET source = EXCLUDED.source, updated_at = now()`,
+		idSong, string(body))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to store chord sheet", "error", err)
+		http.Error(w, "Failed to store chord sheet", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChordSheet{
+		SongID:      idSong,
+		Title:       sheet.title,
+		Key:         sheet.key,
+		OriginalKey: sheet.key,
+		Chords:      sheet.chordNames(),
+		ChordPro:    sheet.chordPro(),
+		Text:        sheet.text(),
+	})
+
+	slog.Debug("Chord sheet stored successfully", "id_song", idSong)
+}
+
+// @Summary Get a chord sheet
+// @Description Get the chord sheet of a song as ChordPro, as plain text with chords above the lyrics (format=text), or both with the chord list (format=json). It can be transposed by a number of semitones or into a target key of the same mode; the sheet's first {key} directive, or else the song's key, is the starting point for the latter, and every later {key} of a modulation moves by the same interval. Accidentals default to the convention of the resulting key, or to each chord's own spelling when the key is unknown.
+// @Tags chords
+// @Produce  plain
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param format query string false "chordpro (default), text or json"
+// @Param transpose query int false "Semitones to transpose by, e.g. -2"
+// @Param key query string false "Key to transpose into, e.g. Bbm"
+// @Param accidentals query string false "sharp or flat"
+// @Success 200 {string} string "The sheet"
+// @Success 200 {object} ChordSheet "The sheet (format=json)"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song has no chord sheet"
+// @Failure 500 {string} string "Failed to fetch chord sheet"
+// @Router /songs/{id_song}/chords [get]
+func getChordSheet(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getChordSheet")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "chordpro" && format != "text" && format != "json" {
+		slog.Warn("Invalid format parameter", "format", format)
+		http.Error(w, "Invalid 'format' parameter, expected chordpro, text or json", http.StatusBadRequest)
+		return
+	}
+	accidentals := query.Get("accidentals")
+	if accidentals != "" && accidentals != accidentalsSharp && accidentals != accidentalsFlat {
+		slog.Warn("Invalid accidentals parameter", "accidentals", accidentals)
+		http.Error(w, "Invalid 'accidentals' parameter, expected sharp or flat", http.StatusBadRequest)
+		return
+	}
+	if query.Get("transpose") != "" && query.Get("key") != "" {
+		slog.Warn("Both transpose and key given")
+		http.Error(w, "Use either 'transpose' or 'key', not both", http.StatusBadRequest)
+		return
+	}
+	semitones := 0
+	if value := query.Get("transpose"); value != "" {
+		var err error
+		if semitones, err = strconv.Atoi(value); err != nil || semitones < -24 || semitones > 24 {
+			slog.Warn("Invalid transpose parameter", "transpose", value)
+			http.Error(w, "Invalid 'transpose' parameter, expected -24 to 24 semitones", http.StatusBadRequest)
+			return
+		}
+	}
+	targetKey := ""
+	if value := query.Get("key"); value != "" {
+		var err error
+		if targetKey, err = normalizeKey(value); err != nil {
+			slog.Warn("Invalid key parameter", "key", value)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var stored struct {
+		Source  string  `db:"source"`
+		SongKey *string `db:"musical_key"`
+	}
+	err := db.Get(&stored, `
+		SELECT c.source, s.musical_key
+		FROM chord_sheets c
+		JOIN songs s ON s.id_song = c.id_song
+		WHERE c.id_song = $1`, idSong)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song has no chord sheet", "id_song", idSong)
+			http.Error(w, "Song has no chord sheet", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch chord sheet", "error", err)
+		http.Error(w, "Failed to fetch chord sheet", http.StatusInternalServerError)
+		return
+	}
+
+	sheet, err := parseChordPro(stored.Source)
+	if err != nil {
+		slog.Error("Stored chord sheet does not parse", "id_song", idSong, "error", err)
+		http.Error(w, "Failed to fetch chord sheet", http.StatusInternalServerError)
+		return
+	}
+	originalKey := sheet.key
+	if originalKey == "" && stored.SongKey != nil {
+		originalKey = *stored.SongKey
+		sheet.key = originalKey
+	}
+
+	if targetKey != "" {
+		if originalKey == "" {
+			slog.Warn("Cannot transpose into a key without the original key", "id_song", idSong)
+			http.Error(w, "The sheet has no {key} and the song no key to transpose from", http.StatusBadRequest)
+			return
+		}
+		if semitones, err = keySemitones(originalKey, targetKey); err != nil {
+			slog.Warn("Invalid target key", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if accidentals == "" && originalKey != "" && (semitones != 0 || targetKey != "") {
+		// Spell chords the way the resulting key is written; a target key asked for as Gb wants flats.
+		accidentals = accidentalsSharp
+		if strings.Contains(targetKey, "b") || flatKeys[transposeChord(originalKey, semitones, accidentalsFlat)] {
+			accidentals = accidentalsFlat
+		}
+	}
+	if semitones != 0 || accidentals != "" {
+		sheet = sheet.transpose(semitones, accidentals)
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ChordSheet{
+			SongID:      idSong,
+			Title:       sheet.title,
+			Key:         sheet.key,
+			OriginalKey: originalKey,
+			Semitones:   ((semitones % 12) + 12) % 12,
+			Chords:      sheet.chordNames(),
+			ChordPro:    sheet.chordPro(),
+			Text:        sheet.text(),
+		})
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(sheet.text()))
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(sheet.chordPro()))
+	}
+}
+
+// @Summary Delete a chord sheet
+// @Description Remove the chord sheet of a song.
+// @Tags chords
+// @Param id_song path int true "ID of the song"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song has no chord sheet"
+// @Failure 500 {string} string "Failed to delete chord sheet"
+// @Router /songs/{id_song}/chords [delete]
+func deleteChordSheet(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request deleteChordSheet")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM chord_sheets WHERE id_song = $1", idSong)
+	if err != nil {
+		slog.Error("Failed to delete chord sheet", "error", err)
+		http.Error(w, "Failed to delete chord sheet", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		slog.Warn("Song has no chord sheet", "id_song", idSong)
+		http.Error(w, "Song has no chord sheet", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
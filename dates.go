@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	precisionYear  = "year"
+	precisionMonth = "month"
+	precisionDay   = "day"
+)
+
+var releaseDateLayouts = []struct {
+	layout    string
+	precision string
+}{
+	{"2006-01-02", precisionDay},
+	{"2006-01", precisionMonth},
+	{"2006", precisionYear},
+}
+
+// releaseDateColumnsSQL renders songs.release_date back to the form it was given in: YYYY, YYYY-MM or YYYY-MM-DD.
+// Unknown dates come back as an empty string.
+const releaseDateColumnsSQL = `COALESCE(CASE s.release_date_precision
+            WHEN 'year' THEN to_char(s.release_date, 'YYYY')
+            WHEN 'month' THEN to_char(s.release_date, 'YYYY-MM')
+            ELSE to_char(s.release_date, 'YYYY-MM-DD')
+        END, '') AS release_date, COALESCE(s.release_date_precision, '') AS release_date_precision`
+
+// precisionRankSQL orders precisions from coarsest to finest so "2006" sorts before "2006-01-01".
+const precisionRankSQL = `CASE s.release_date_precision WHEN 'year' THEN 1 WHEN 'month' THEN 2 WHEN 'day' THEN 3 END`
+
+// parseReleaseDate accepts YYYY, YYYY-MM or YYYY-MM-DD and returns the first day of that period with its precision.
+func parseReleaseDate(value string) (time.Time, string, error) {
+	value = strings.TrimSpace(value)
+	for _, l := range releaseDateLayouts {
+		if date, err := time.Parse(l.layout, value); err == nil {
+			return date, l.precision, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("invalid release date %q, expected YYYY, YYYY-MM or YYYY-MM-DD", value)
+}
+
+// formatReleaseDate renders a date with no more digits than its precision.
+func formatReleaseDate(date time.Time, precision string) string {
+	for _, l := range releaseDateLayouts {
+		if l.precision == precision {
+			return date.Format(l.layout)
+		}
+	}
+	return date.Format(time.DateOnly)
+}
+
+// releaseDatePeriodEnd returns the first day after the period that starts at date.
+func releaseDatePeriodEnd(date time.Time, precision string) time.Time {
+	switch precision {
+	case precisionYear:
+		return date.AddDate(1, 0, 0)
+	case precisionMonth:
+		return date.AddDate(0, 1, 0)
+	default:
+		return date.AddDate(0, 0, 1)
+	}
+}
+
+func precisionRank(precision string) int {
+	switch precision {
+	case precisionYear:
+		return 1
+	case precisionMonth:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// dateFormat is a user-configured date layout such as "DD.MM.YYYY".
+type dateFormat struct {
+	pattern   string
+	layout    string
+	precision string
+}
+
+// defaultDateFormats accepts our own partial dates and the DD.MM.YYYY form of the original upstream contract.
+const defaultDateFormats = "YYYY-MM-DD,YYYY-MM,YYYY,DD.MM.YYYY"
+
+// parseDateFormats reads comma-separated patterns built from YYYY, MM, M, DD, D and separators.
+func parseDateFormats(value string) ([]dateFormat, error) {
+	var formats []dateFormat
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		var layout strings.Builder
+		hasYear, hasMonth, hasDay := false, false, false
+		for rest := pattern; rest != ""; {
+			switch {
+			case strings.HasPrefix(rest, "YYYY"):
+				layout.WriteString("2006")
+				rest, hasYear = rest[4:], true
+			case strings.HasPrefix(rest, "MM"):
+				layout.WriteString("01")
+				rest, hasMonth = rest[2:], true
+			case strings.HasPrefix(rest, "DD"):
+				layout.WriteString("02")
+				rest, hasDay = rest[2:], true
+			case strings.HasPrefix(rest, "M"):
+				layout.WriteString("1")
+				rest, hasMonth = rest[1:], true
+			case strings.HasPrefix(rest, "D"):
+				layout.WriteString("2")
+				rest, hasDay = rest[1:], true
+			case strings.ContainsRune(" .-/", rune(rest[0])):
+				layout.WriteByte(rest[0])
+				rest = rest[1:]
+			default:
+				return nil, fmt.Errorf("invalid date format %q: unexpected %q", pattern, rest[:1])
+			}
+		}
+
+		format := dateFormat{pattern: pattern, layout: layout.String()}
+		switch {
+		case hasYear && hasMonth && hasDay:
+			format.precision = precisionDay
+		case hasYear && hasMonth && !hasDay:
+			format.precision = precisionMonth
+		case hasYear && !hasMonth && !hasDay:
+			format.precision = precisionYear
+		default:
+			return nil, fmt.Errorf("invalid date format %q: needs YYYY, YYYY with MM, or YYYY with MM and DD", pattern)
+		}
+		formats = append(formats, format)
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no date formats given")
+	}
+	return formats, nil
+}
+
+// normalizeDate parses value with the first matching format and renders it as YYYY, YYYY-MM or YYYY-MM-DD.
+func normalizeDate(formats []dateFormat, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	patterns := make([]string, 0, len(formats))
+	for _, format := range formats {
+		if date, err := time.Parse(format.layout, value); err == nil {
+			return formatReleaseDate(date, format.precision), nil
+		}
+		patterns = append(patterns, format.pattern)
+	}
+	return "", fmt.Errorf("invalid release date %q, expected %s", value, strings.Join(patterns, ", "))
+}
+
+// releaseDateArgs turns a partial date into the release_date and release_date_precision column values; an empty value stores NULL.
+func releaseDateArgs(value string) (interface{}, interface{}, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil, nil
+	}
+	date, precision, err := parseReleaseDate(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return date.Format(time.DateOnly), precision, nil
+}
@@ -15,9 +15,52 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/info": {
+        "/enrichment/cache": {
+            "get": {
+                "description": "Get hit and miss counters of the cache in front of the enrichment providers since startup. Negative hits are cached \"not found\" answers; bypasses are lookups made by refreshes.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "enrichment"
+                ],
+                "summary": "Response cache metrics",
+                "responses": {
+                    "200": {
+                        "description": "Cache metrics",
+                        "schema": {
+                            "$ref": "#/definitions/main.CacheStats"
+                        }
+                    }
+                }
+            }
+        },
+        "/enrichment/limits": {
+            "get": {
+                "description": "Get the limits of every external API client with the number of queued and in-flight requests, time spent waiting for a slot, and 429 answers received since startup.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "enrichment"
+                ],
+                "summary": "Outbound rate limit metrics",
+                "responses": {
+                    "200": {
+                        "description": "Limiter metrics per provider",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.LimiterStats"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/groups/by-external-id": {
             "get": {
-                "description": "Get releaseDate, text, link for a song based on group and song.",
+                "description": "Find a group by an external provider ID, e.g. provider=musicbrainz and an artist MBID.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,46 +68,46 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "identifiers"
                 ],
-                "summary": "Music info",
+                "summary": "Group by external ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Group of the song",
-                        "name": "group",
+                        "description": "Provider key",
+                        "name": "provider",
                         "in": "query",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Title of the song",
-                        "name": "song",
+                        "description": "ID at the provider",
+                        "name": "id",
                         "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Song details",
+                        "description": "The group",
                         "schema": {
-                            "$ref": "#/definitions/main.SongDetail"
+                            "$ref": "#/definitions/main.Group"
                         }
                     },
                     "400": {
-                        "description": "Missing required parameters 'group' or 'song'",
+                        "description": "Invalid parameters",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "Song not found",
+                        "description": "Group not found",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Failed to fetch song details",
+                        "description": "Failed to fetch group",
                         "schema": {
                             "type": "string"
                         }
@@ -72,71 +115,36 @@ const docTemplate = `{
                 }
             }
         },
-        "/songs": {
+        "/groups/{id_group}/analysis": {
             "get": {
-                "description": "Retrieve a list of songs with optional filters: group name, song name, release date, text, link, and pagination by songs.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Aggregate the lyrics statistics of all songs of a group that have lyrics: total and unique words across them, the most frequent words overall, averages of the repeated-line share and reading ease, and how many sections use each rhyme pattern.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "analysis"
                 ],
-                "summary": "Get songs with optional filters and pagination",
+                "summary": "Group lyrics analysis",
                 "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Filter by group name",
-                        "name": "group",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by song name",
-                        "name": "song",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by release date (YYYY-MM-DD)",
-                        "name": "release_date",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by text",
-                        "name": "text",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by link",
-                        "name": "link",
-                        "in": "query"
-                    },
                     {
                         "type": "integer",
-                        "description": "Page number (default is 1)",
-                        "name": "page",
-                        "in": "query"
+                        "description": "ID of the group",
+                        "name": "id_group",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "integer",
-                        "description": "Number of songs per page (default is 10)",
-                        "name": "limit",
+                        "description": "Number of frequent words (default 10, at most 100)",
+                        "name": "top",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Paginated list of songs",
+                        "description": "Aggregated statistics",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/main.Song"
-                            }
+                            "$ref": "#/definitions/main.GroupAnalysis"
                         }
                     },
                     "400": {
@@ -145,16 +153,24 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "404": {
+                        "description": "Group not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
-                        "description": "Failed to fetch songs",
+                        "description": "Failed to analyze lyrics",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/groups/{id_group}/external-ids/{provider}": {
             "put": {
-                "description": "Update the details of an existing song.",
+                "description": "Attach or replace the ID of a group at an external provider. MusicBrainz IDs must be valid UUIDs.",
                 "consumes": [
                     "application/json"
                 ],
@@ -162,32 +178,39 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "identifiers"
                 ],
-                "summary": "Update song details",
+                "summary": "Set a group external ID",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "ID of the song",
-                        "name": "id_song",
-                        "in": "query",
+                        "description": "ID of the group",
+                        "name": "id_group",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Updated song details",
-                        "name": "song",
+                        "type": "string",
+                        "description": "Provider key, e.g. musicbrainz",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "ID at the provider",
+                        "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/main.Song"
+                            "$ref": "#/definitions/main.ExternalID"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "The updated song",
+                        "description": "The group with its external IDs",
                         "schema": {
-                            "$ref": "#/definitions/main.Song"
+                            "$ref": "#/definitions/main.Group"
                         }
                     },
                     "400": {
@@ -197,21 +220,27 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Song not found",
+                        "description": "Group not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "External ID already used by another group",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Failed to update song",
+                        "description": "Failed to set external ID",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             },
-            "post": {
-                "description": "Add a new song by providing group name and song name. Details (release date, text, link) are fetched from an external API.",
+            "delete": {
+                "description": "Remove the ID of a group at an external provider.",
                 "consumes": [
                     "application/json"
                 ],
@@ -219,49 +248,53 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "identifiers"
                 ],
-                "summary": "Add a new song",
+                "summary": "Delete a group external ID",
                 "parameters": [
                     {
-                        "description": "Group and Song names",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/main.SongShort"
-                        }
+                        "type": "integer",
+                        "description": "ID of the group",
+                        "name": "id_group",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Provider key",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Song added successfully",
-                        "schema": {
-                            "type": "string"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
-                        "description": "Invalid input",
+                        "description": "Invalid parameters",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "Song details not found",
+                        "description": "External ID not found",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Failed to add song",
+                        "description": "Failed to delete external ID",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a song from the database by its ID.",
+            }
+        },
+        "/info": {
+            "get": {
+                "description": "Get releaseDate, text, link for a song based on group and song. The text comes in the language negotiated from lang or Accept-Language, falling back to the original lyrics; Content-Language names it when known. With clean=true profane words of the text are masked.",
                 "consumes": [
                     "application/json"
                 ],
@@ -271,22 +304,50 @@ const docTemplate = `{
                 "tags": [
                     "songs"
                 ],
-                "summary": "Delete a song",
+                "summary": "Music info",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "ID of the song",
-                        "name": "id_song",
+                        "type": "string",
+                        "description": "Group of the song",
+                        "name": "group",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Title of the song",
+                        "name": "song",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the text; overrides Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Preferred languages of the text",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Mask words of the profanity lexicon, keeping their first letter",
+                        "name": "clean",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "Song details",
+                        "schema": {
+                            "$ref": "#/definitions/main.SongDetail"
+                        }
                     },
                     "400": {
-                        "description": "Invalid parameters",
+                        "description": "Missing required parameters 'group' or 'song'",
                         "schema": {
                             "type": "string"
                         }
@@ -298,7 +359,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Failed to delete song",
+                        "description": "Failed to fetch song details",
                         "schema": {
                             "type": "string"
                         }
@@ -306,107 +367,3396 @@ const docTemplate = `{
                 }
             }
         },
-        "/songs/text": {
-            "get": {
-                "description": "Fetch the song text with pagination by verses.",
-                "consumes": [
+        "/profanity/rescan": {
+            "post": {
+                "description": "Check the lyrics of every song against the current lexicon, as is done whenever lyrics are stored or updated: matching songs are flagged explicit, and flags the lexicon set before are dropped from songs that no longer match. Explicit flags set with PUT /songs are left alone.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "profanity"
+                ],
+                "summary": "Re-scan lyrics for profanity",
+                "responses": {
+                    "200": {
+                        "description": "Number of songs scanned, flagged and cleared",
+                        "schema": {
+                            "$ref": "#/definitions/main.ProfanityRescan"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to re-scan songs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/profanity/terms": {
+            "get": {
+                "description": "List the lexicon used to flag explicit lyrics and mask them with clean=true. A * at the start or end of a term matches any letters there.",
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "profanity"
                 ],
-                "summary": "Get song text with pagination",
+                "summary": "List profanity terms",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "ID of the song",
-                        "name": "id_song",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page number (default is 1)",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Number of verses per page (default is 1)",
-                        "name": "limit",
+                        "type": "string",
+                        "description": "Only terms of this language, e.g. en",
+                        "name": "language",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Song text or a portion of it",
+                        "description": "Lexicon terms",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.ProfanityTerm"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid parameters",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "404": {
-                        "description": "Song not found or no text available",
+                        "description": "Invalid language",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Failed to fetch song text",
+                        "description": "Failed to fetch profanity terms",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "main.Song": {
-            "type": "object",
-            "properties": {
-                "group": {
-                    "type": "string"
-                },
-                "id_group": {
-                    "type": "integer"
-                },
-                "id_song": {
-                    "type": "integer"
+            },
+            "post": {
+                "description": "Add a word to the lexicon of a language, stored lowercase under the primary language subtag (en for en-GB). A * at the start or end of the term matches any letters there, e.g. fuck* or *хуй*. Songs are not re-checked until POST /profanity/rescan or their lyrics change.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profanity"
+                ],
+                "summary": "Add a profanity term",
+                "parameters": [
+                    {
+                        "description": "Language and term",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProfanityTerm"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The added term",
+                        "schema": {
+                            "$ref": "#/definitions/main.ProfanityTerm"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Term already in the lexicon",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to add profanity term",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/profanity/terms/{id_term}": {
+            "delete": {
+                "description": "Remove a term from the lexicon. Songs are not re-checked until POST /profanity/rescan or their lyrics change.",
+                "tags": [
+                    "profanity"
+                ],
+                "summary": "Delete a profanity term",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the term",
+                        "name": "id_term",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Term not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete profanity term",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs": {
+            "get": {
+                "description": "Retrieve a list of songs with optional filters: group name, song name, release date, text, link, tempo, duration, key, time signature, explicit flag, lyrics language, and pagination by songs.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get songs with optional filters and pagination",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by group name",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by song name",
+                        "name": "song",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by release date (YYYY, YYYY-MM or YYYY-MM-DD); matches songs known at least to that precision within the period",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Songs released on or after the start of this period (YYYY, YYYY-MM or YYYY-MM-DD)",
+                        "name": "release_date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Songs released on or before the end of this period (YYYY, YYYY-MM or YYYY-MM-DD)",
+                        "name": "release_date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum tempo in BPM",
+                        "name": "bpm_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Maximum tempo in BPM",
+                        "name": "bpm_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum duration in seconds",
+                        "name": "duration_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum duration in seconds",
+                        "name": "duration_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Musical key, e.g. C#m",
+                        "name": "key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time signature, e.g. 3/4",
+                        "name": "time_signature",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter by explicit-content flag",
+                        "name": "explicit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order: release_date or -release_date (default is by ID)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by text",
+                        "name": "text",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by link",
+                        "name": "link",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only songs with a link of this provider: youtube, spotify, bandcamp, soundcloud, generic",
+                        "name": "provider",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the lyrics, e.g. en (also matches en-GB) or pt-BR; und for songs without a known language",
+                        "name": "language",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number (default is 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of songs per page (default is 10)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Paginated list of songs",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Song"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch songs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update the details of an existing song. The lyrics language is detected from the text unless language is given, which sets it by hand; an empty language goes back to detection.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Update song details",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated song details",
+                        "name": "song",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.Song"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, kept in the lyrics history (default anonymous)",
+                        "name": "X-Author",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The updated song",
+                        "schema": {
+                            "$ref": "#/definitions/main.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "ISRC already used by another song, or link the song already has",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update song",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a new song by providing group name and song name. Details (release date, text, link) are fetched from the enrichment providers according to the policy: strict fetches them first and rejects the song on failure, lenient fetches them first and stores the song with empty details on failure, deferred stores the song with enrichment_status \"pending\" and fetches details in the background (poll GET /songs/{id_song}/enrichment).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Add a new song",
+                "parameters": [
+                    {
+                        "description": "Group and Song names",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.SongShort"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Enrichment policy: strict, lenient or deferred (default is ENRICHMENT_POLICY)",
+                        "name": "policy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only preview the song: details are fetched whatever the policy, nothing is stored",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Preview of the song (dry_run)",
+                        "schema": {
+                            "$ref": "#/definitions/main.SongPreview"
+                        }
+                    },
+                    "201": {
+                        "description": "The added song",
+                        "schema": {
+                            "$ref": "#/definitions/main.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song details not found (strict policy)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to add song",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Failed to fetch song details (strict policy)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a song from the database by its ID.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Delete a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete song",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/by-external-id": {
+            "get": {
+                "description": "Find a song by an external provider ID, e.g. provider=musicbrainz and a recording MBID.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "identifiers"
+                ],
+                "summary": "Song by external ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider key",
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID at the provider",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The song",
+                        "schema": {
+                            "$ref": "#/definitions/main.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch song",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/by-isrc/{isrc}": {
+            "get": {
+                "description": "Find a song by its International Standard Recording Code. Hyphens are optional.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "identifiers"
+                ],
+                "summary": "Song by ISRC",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ISRC, e.g. US-RC1-76-07839",
+                        "name": "isrc",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The song",
+                        "schema": {
+                            "$ref": "#/definitions/main.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ISRC",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch song",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/by-iswc/{iswc}": {
+            "get": {
+                "description": "Find every recording of a musical work by its International Standard Musical Work Code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "identifiers"
+                ],
+                "summary": "Songs by ISWC",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ISWC, e.g. T-034.524.680-1",
+                        "name": "iswc",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recordings of the work",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Song"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ISWC",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No songs found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch songs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/facets/language": {
+            "get": {
+                "description": "Count the songs per language of their lyrics, most frequent first; songs whose language is unknown are counted under und. Takes the filters of GET /songs except language, so the counts tell how many songs each language would select.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Song counts by lyrics language",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by group name",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by song name",
+                        "name": "song",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by release date (YYYY, YYYY-MM or YYYY-MM-DD)",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by text",
+                        "name": "text",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter by explicit-content flag",
+                        "name": "explicit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Songs per language",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.LanguageFacet"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to count songs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/refresh": {
+            "post": {
+                "description": "Re-fetch details from the enrichment providers for one song, a group, or stale songs (failed, without lyrics or not enriched recently, and not attempted recently). Fetched values replace stored ones; fields the providers don't return are kept. With dry_run nothing is stored and the response lists what would change. A single song is refreshed immediately, groups and stale songs are queued.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "enrichment"
+                ],
+                "summary": "Refresh song details",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song to refresh",
+                        "name": "id_song",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the group whose songs to refresh",
+                        "name": "id_group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Refresh stale songs",
+                        "name": "stale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only show what would change",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of songs (default 50, at most 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Refreshed, queued or previewed songs",
+                        "schema": {
+                            "$ref": "#/definitions/main.RefreshReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song or group not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to refresh songs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/text": {
+            "get": {
+                "description": "Fetch the song text, or a page of it by verses, lines or characters, in the language negotiated from lang or Accept-Language and named by Content-Language. Text responses carry the totals in X-Total-Items, X-Total-Pages and X-Page headers.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain",
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get song text with pagination",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number (default is 1); pages past the end are empty",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of units per page (default is 1 verse, 10 lines or 1000 characters; every section with format=json and unit=verse)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "verse (default), line or char. Text verses are the blank-line separated blocks as written, headers included; JSON verses are the sections recognized from markup like [Chorus] or Verse 2:, which total_verses counts for every unit",
+                        "name": "unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "text (default) or json, an envelope with the totals, page count and sections",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the text, a translation or the original lyrics; overrides Accept-Language, and a language without lyrics is a 404",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Preferred languages of the text",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Follow each original section with the translated one of the same type and number, like the second chorus (unit=verse only); pages are then sections in both formats",
+                        "name": "interleave",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Mask words of the profanity lexicon of the text's language with asterisks, keeping their first letter and the length of the text",
+                        "name": "clean",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page of the song text (format=json)",
+                        "schema": {
+                            "$ref": "#/definitions/main.SongTextPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found, no text or no translation available",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch song text",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/analysis": {
+            "get": {
+                "description": "Get statistics of a song's lyrics: word count, unique words and lexical density, the most frequent words without stop words (English and Russian lists), the share of repeated lines, the rhyme scheme of every section (AABB couplet, ABAB alternate, ABBA enclosed, monorhyme or free) and the Flesch reading ease, with Oborneva's coefficients for Russian. The language is the song's own or is guessed from the alphabet.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analysis"
+                ],
+                "summary": "Lyrics analysis",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of frequent words (default 10, at most 100)",
+                        "name": "top",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Lyrics statistics",
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricsAnalysis"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to analyze lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/annotations": {
+            "get": {
+                "description": "Get the annotations of a song ordered by position. Use orphaned to get only those whose text was lost in an edit (true) or only anchored ones (false).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "annotations"
+                ],
+                "summary": "List annotations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter by orphaned flag",
+                        "name": "orphaned",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Annotations of the song",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Annotation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch annotations",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Attach a note to a line range of a song's lyrics, or to a character span within it when start_char and end_char are given. Lines are numbered from 1 as in the stored lyrics, blank lines included, and characters from 0. When the lyrics are edited the annotation follows its text or, if the text is gone, is flagged as orphaned until a later edit brings the text back.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "annotations"
+                ],
+                "summary": "Annotate lyrics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Anchor (start_line, end_line, optional start_char and end_char) and body",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.Annotation"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who writes the annotation (default anonymous)",
+                        "name": "X-Author",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The created annotation",
+                        "schema": {
+                            "$ref": "#/definitions/main.Annotation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store annotation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/annotations/{id_annotation}": {
+            "get": {
+                "description": "Get one annotation of a song.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "annotations"
+                ],
+                "summary": "Get an annotation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the annotation",
+                        "name": "id_annotation",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The annotation",
+                        "schema": {
+                            "$ref": "#/definitions/main.Annotation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Annotation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch annotation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the anchor and body of an annotation. Anchoring an orphaned annotation again clears its orphaned flag.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "annotations"
+                ],
+                "summary": "Update an annotation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the annotation",
+                        "name": "id_annotation",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Anchor (start_line, end_line, optional start_char and end_char) and body",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.Annotation"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The updated annotation",
+                        "schema": {
+                            "$ref": "#/definitions/main.Annotation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Annotation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store annotation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove an annotation from a song.",
+                "tags": [
+                    "annotations"
+                ],
+                "summary": "Delete an annotation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the annotation",
+                        "name": "id_annotation",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Annotation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete annotation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/chords": {
+            "get": {
+                "description": "Get the chord sheet of a song as ChordPro, as plain text with chords above the lyrics (format=text), or both with the chord list (format=json). It can be transposed by a number of semitones or into a target key of the same mode; the sheet's first {key} directive, or else the song's key, is the starting point for the latter, and every later {key} of a modulation moves by the same interval. Accidentals default to the convention of the resulting key, or to each chord's own spelling when the key is unknown.",
+                "produces": [
+                    "text/plain",
+                    "application/json"
+                ],
+                "tags": [
+                    "chords"
+                ],
+                "summary": "Get a chord sheet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "chordpro (default), text or json",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Semitones to transpose by, e.g. -2",
+                        "name": "transpose",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to transpose into, e.g. Bbm",
+                        "name": "key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sharp or flat",
+                        "name": "accidentals",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The sheet (format=json)",
+                        "schema": {
+                            "$ref": "#/definitions/main.ChordSheet"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song has no chord sheet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch chord sheet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Store a ChordPro sheet for a song, replacing any stored before. Directives must be known ChordPro ones (custom ones start with x_), chords must be valid, like [F#m7/C#], and start_of/end_of sections must match.",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chords"
+                ],
+                "summary": "Upload a chord sheet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "ChordPro sheet",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The stored sheet",
+                        "schema": {
+                            "$ref": "#/definitions/main.ChordSheet"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ChordPro",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store chord sheet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove the chord sheet of a song.",
+                "tags": [
+                    "chords"
+                ],
+                "summary": "Delete a chord sheet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song has no chord sheet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete chord sheet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/enrichment": {
+            "get": {
+                "description": "Get the status of fetching details from the external API: pending, done, failed or not_found. With 'wait' the request blocks until the song is no longer pending or the wait expires.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "enrichment"
+                ],
+                "summary": "Song enrichment status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Long-poll duration, e.g. 30s (at most 1m)",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Enrichment status",
+                        "schema": {
+                            "$ref": "#/definitions/main.EnrichmentStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch enrichment status",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/external-ids/{provider}": {
+            "put": {
+                "description": "Attach or replace the ID of a song at an external provider. MusicBrainz IDs must be valid UUIDs.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "identifiers"
+                ],
+                "summary": "Set a song external ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Provider key, e.g. musicbrainz",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "ID at the provider",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ExternalID"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The song with its external IDs",
+                        "schema": {
+                            "$ref": "#/definitions/main.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "External ID already used by another song",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to set external ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove the ID of a song at an external provider.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "identifiers"
+                ],
+                "summary": "Delete a song external ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Provider key",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "External ID not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete external ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/karaoke": {
+            "get": {
+                "description": "Get the line, and with enhanced LRC the word, being sung at a playback position, with up to 'window' lines before and after it. Before the first line there is no active line and 'after' starts with the first one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Karaoke position lookup",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Playback position in milliseconds",
+                        "name": "position",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Lines to return before and after the active one (default 0, at most 50)",
+                        "name": "window",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Active line and its surroundings",
+                        "schema": {
+                            "$ref": "#/definitions/main.KaraokeFrame"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song has no synced lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch synced lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/links": {
+            "get": {
+                "description": "Get every link of a song with its detected provider.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Song links",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Links of the song",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.SongLink"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch links",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Attach a link to a song. The provider (youtube, spotify, bandcamp, soundcloud, generic) is detected from the URL.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Add a song link",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "URL of the link",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.SongLink"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The created link",
+                        "schema": {
+                            "$ref": "#/definitions/main.SongLink"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Song already has this link",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to add link",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/links/{id_link}": {
+            "delete": {
+                "description": "Remove a link from a song.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Delete a song link",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the link",
+                        "name": "id_link",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Link not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete link",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/lrc": {
+            "get": {
+                "description": "Get the synced lyrics of a song as LRC, or as JSON with format=json.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain",
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Export time-synced lyrics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include word timestamps (default true)",
+                        "name": "enhanced",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "lrc (default) or json",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Synced lyrics (format=json)",
+                        "schema": {
+                            "$ref": "#/definitions/main.SyncedLyrics"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song has no synced lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch synced lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Store LRC lyrics for a song, replacing any stored before. Enhanced LRC word timestamps (\u003cmm:ss.xx\u003e) are kept. Lines must not go back in time and word timestamps must rise within their line; the [offset] tag is applied to all timestamps.",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Upload time-synced lyrics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "LRC text",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The stored lyrics",
+                        "schema": {
+                            "$ref": "#/definitions/main.SyncedLyrics"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid LRC",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store synced lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove the synced lyrics of a song.",
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Delete time-synced lyrics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song has no synced lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete synced lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/lyrics/annotated": {
+            "get": {
+                "description": "Get the lyrics of a song line by line, each line with the anchors of the annotations covering it: the annotation ID and, for character spans, the covered columns of that line. Orphaned annotations are counted but not anchored.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "annotations"
+                ],
+                "summary": "Annotated lyrics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Lyrics with annotation anchors",
+                        "schema": {
+                            "$ref": "#/definitions/main.AnnotatedLyrics"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch annotations",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/lyrics/diff": {
+            "get": {
+                "description": "Compare two revisions of a song's lyrics line by line. 'to' defaults to the latest revision and 'from' to the one before 'to'. Each line of the result is equal, insert or delete, with its line numbers in the old and new text.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Diff two lyrics revisions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Older revision (default is the one before 'to')",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Newer revision (default is the latest)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Line-level diff",
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricsDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Revision not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to diff revisions",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/lyrics/revisions": {
+            "get": {
+                "description": "List every stored revision of a song's lyrics, newest first, with author, source (import, edit, enrichment or rollback) and time. The text is left out; fetch a single revision for it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Lyrics revision history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Revisions of the lyrics",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.LyricsRevision"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch revisions",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/lyrics/revisions/{revision}": {
+            "get": {
+                "description": "Get one revision of a song's lyrics with its text.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Get a lyrics revision",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision number",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The revision",
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricsRevision"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Revision not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch revision",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/lyrics/revisions/{revision}/rollback": {
+            "post": {
+                "description": "Restore the lyrics of an old revision. The restore is itself recorded as a new revision, so history is never rewritten; when the lyrics already match, nothing changes and the latest revision is returned with 200.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lyrics"
+                ],
+                "summary": "Roll lyrics back to a revision",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision to restore",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change (default anonymous)",
+                        "name": "X-Author",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Lyrics already matched the revision",
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricsRevision"
+                        }
+                    },
+                    "201": {
+                        "description": "The new revision",
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricsRevision"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Revision not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to roll back lyrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/relations": {
+            "post": {
+                "description": "Record that a song is a cover, remix, live version or translation of another song.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "relations"
+                ],
+                "summary": "Link a song to its original",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the derived song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Original song ID and relation type (cover, remix, live, translation)",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.SongRelation"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The created relation",
+                        "schema": {
+                            "$ref": "#/definitions/main.SongRelation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Relation already exists or would create a cycle",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to link songs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove the relation between a derived song and its original. Without 'type' all relations between the two songs are removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "relations"
+                ],
+                "summary": "Unlink a song from its original",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the derived song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the original song",
+                        "name": "id_original",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Relation type (cover, remix, live, translation)",
+                        "name": "type",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Relation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to unlink songs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/translations": {
+            "get": {
+                "description": "Get all translations of a song's lyrics, ordered by language.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "translations"
+                ],
+                "summary": "List lyric translations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Translations of the song",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.LyricTranslation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch translations",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/translations/{language}": {
+            "get": {
+                "description": "Get the translation of a song's lyrics into one language.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "translations"
+                ],
+                "summary": "Get a lyric translation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language tag, e.g. en or pt-BR",
+                        "name": "language",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The translation",
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricTranslation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Translation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch translation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Store the translation of a song's lyrics into one language. The text is normalized like the original lyrics and may use the same section markup, so it can be aligned with the original by section.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "translations"
+                ],
+                "summary": "Create or replace a lyric translation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language tag, e.g. en or pt-BR",
+                        "name": "language",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Translated lyrics in the text field",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricTranslation"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The replaced translation",
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricTranslation"
+                        }
+                    },
+                    "201": {
+                        "description": "The created translation",
+                        "schema": {
+                            "$ref": "#/definitions/main.LyricTranslation"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store translation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove the translation of a song's lyrics into one language.",
+                "tags": [
+                    "translations"
+                ],
+                "summary": "Delete a lyric translation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language tag, e.g. en or pt-BR",
+                        "name": "language",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Translation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete translation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id_song}/versions": {
+            "get": {
+                "description": "Get the whole family tree of a song: its originals up to the root and every cover, remix, live version and translation below them.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "relations"
+                ],
+                "summary": "Song versions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id_song",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Root songs of the family with nested versions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.SongVersion"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch song versions",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "main.AnnotatedLine": {
+            "type": "object",
+            "properties": {
+                "anchors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.AnnotationAnchor"
+                    }
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.AnnotatedLyrics": {
+            "type": "object",
+            "properties": {
+                "annotations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.Annotation"
+                    }
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.AnnotatedLine"
+                    }
+                },
+                "orphaned": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.Annotation": {
+            "type": "object",
+            "properties": {
+                "author": {
+                    "type": "string"
+                },
+                "body": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "end_char": {
+                    "type": "integer"
+                },
+                "end_line": {
+                    "type": "integer"
+                },
+                "id_annotation": {
+                    "type": "integer"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "orphaned": {
+                    "type": "boolean"
+                },
+                "quote": {
+                    "description": "Quote is the annotated text, used to find it again after the lyrics are edited.",
+                    "type": "string"
+                },
+                "start_char": {
+                    "type": "integer"
+                },
+                "start_line": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.AnnotationAnchor": {
+            "type": "object",
+            "properties": {
+                "end_char": {
+                    "type": "integer"
+                },
+                "id_annotation": {
+                    "type": "integer"
+                },
+                "start_char": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.CacheStats": {
+            "type": "object",
+            "properties": {
+                "bypasses": {
+                    "type": "integer"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "entries": {
+                    "type": "integer"
+                },
+                "evictions": {
+                    "type": "integer"
+                },
+                "hit_ratio": {
+                    "type": "number"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "negative_hits": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ChordSheet": {
+            "type": "object",
+            "properties": {
+                "chordpro": {
+                    "type": "string"
+                },
+                "chords": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "original_key": {
+                    "type": "string"
+                },
+                "semitones": {
+                    "description": "Semitones is how far the sheet was transposed up, from 0 to 11.",
+                    "type": "integer"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.DiffLine": {
+            "type": "object",
+            "properties": {
+                "new_line": {
+                    "type": "integer"
+                },
+                "old_line": {
+                    "type": "integer"
+                },
+                "op": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.EnrichmentStatus": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "next_attempt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.ExternalID": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.FieldChange": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "new": {},
+                "old": {}
+            }
+        },
+        "main.Group": {
+            "type": "object",
+            "properties": {
+                "external_ids": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "group": {
+                    "type": "string"
+                },
+                "id_group": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.GroupAnalysis": {
+            "type": "object",
+            "properties": {
+                "avg_readability": {
+                    "type": "number"
+                },
+                "avg_repeated_line_ratio": {
+                    "type": "number"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "id_group": {
+                    "type": "integer"
+                },
+                "rhyme_patterns": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "songs": {
+                    "type": "integer"
+                },
+                "top_words": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.WordCount"
+                    }
+                },
+                "unique_words": {
+                    "type": "integer"
+                },
+                "word_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.KaraokeFrame": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.SyncedLine"
+                    }
+                },
+                "before": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.SyncedLine"
+                    }
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "line": {
+                    "$ref": "#/definitions/main.SyncedLine"
+                },
+                "position_ms": {
+                    "type": "integer"
+                },
+                "word_index": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.LanguageFacet": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "language": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.LimiterStats": {
+            "type": "object",
+            "properties": {
+                "avg_wait_ms": {
+                    "type": "integer"
+                },
+                "burst": {
+                    "type": "integer"
+                },
+                "in_flight": {
+                    "type": "integer"
+                },
+                "max_in_flight": {
+                    "type": "integer"
+                },
+                "max_wait_ms": {
+                    "type": "integer"
+                },
+                "paused_until": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "queued": {
+                    "type": "integer"
+                },
+                "rate_limit": {
+                    "type": "number"
+                },
+                "requests": {
+                    "type": "integer"
+                },
+                "throttled": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.LyricSection": {
+            "type": "object",
+            "properties": {
+                "index": {
+                    "type": "integer"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "number": {
+                    "type": "integer"
+                },
+                "repeat": {
+                    "type": "boolean"
+                },
+                "translation": {
+                    "description": "Translation holds the lines of the matching translated section in interleaved mode.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.LyricTranslation": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.LyricsAnalysis": {
+            "type": "object",
+            "properties": {
+                "id_song": {
+                    "type": "integer"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "lexical_density": {
+                    "type": "number"
+                },
+                "line_count": {
+                    "type": "integer"
+                },
+                "readability": {
+                    "type": "number"
+                },
+                "repeated_line_ratio": {
+                    "type": "number"
+                },
+                "top_words": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.WordCount"
+                    }
+                },
+                "unique_words": {
+                    "type": "integer"
+                },
+                "verses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.VerseRhyme"
+                    }
+                },
+                "word_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.LyricsDiff": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.DiffLine"
+                    }
+                },
+                "removed": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.LyricsRevision": {
+            "type": "object",
+            "properties": {
+                "author": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "lines": {
+                    "type": "integer"
+                },
+                "restored_from": {
+                    "type": "integer"
+                },
+                "revision": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.ProfanityRescan": {
+            "type": "object",
+            "properties": {
+                "cleared": {
+                    "type": "integer"
+                },
+                "flagged": {
+                    "type": "integer"
+                },
+                "scanned": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ProfanityTerm": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id_term": {
+                    "type": "integer"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "term": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.RefreshReport": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "songs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.RefreshResult"
+                    }
+                }
+            }
+        },
+        "main.RefreshResult": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.FieldChange"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "song": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.Song": {
+            "type": "object",
+            "properties": {
+                "bpm": {
+                    "type": "number"
+                },
+                "duration": {
+                    "type": "integer"
+                },
+                "enrichment_status": {
+                    "type": "string"
+                },
+                "explicit": {
+                    "type": "boolean"
+                },
+                "external_ids": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "group": {
+                    "type": "string"
+                },
+                "id_group": {
+                    "type": "integer"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "isrc": {
+                    "type": "string"
+                },
+                "iswc": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "language": {
+                    "description": "Language is the language tag of the lyrics, e.g. en; translations are stored separately.\nUnless set by hand it is detected from the lyrics, with the confidence of the detection.",
+                    "type": "string"
+                },
+                "language_confidence": {
+                    "type": "number"
+                },
+                "language_source": {
+                    "type": "string"
                 },
                 "link": {
                     "type": "string"
                 },
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.SongLink"
+                    }
+                },
                 "release_date": {
                     "type": "string"
                 },
+                "release_date_precision": {
+                    "type": "string"
+                },
                 "song": {
                     "type": "string"
                 },
+                "sources": {
+                    "$ref": "#/definitions/main.fieldSources"
+                },
                 "text": {
                     "type": "string"
+                },
+                "time_signature": {
+                    "type": "string"
                 }
             }
         },
         "main.SongDetail": {
             "type": "object",
             "properties": {
+                "bpm": {
+                    "type": "number"
+                },
+                "duration": {
+                    "type": "integer"
+                },
+                "explicit": {
+                    "type": "boolean"
+                },
+                "key": {
+                    "type": "string"
+                },
                 "link": {
                     "type": "string"
                 },
                 "release_date": {
                     "type": "string"
                 },
+                "release_date_precision": {
+                    "type": "string"
+                },
+                "sources": {
+                    "$ref": "#/definitions/main.fieldSources"
+                },
                 "text": {
                     "type": "string"
+                },
+                "time_signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.SongLink": {
+            "type": "object",
+            "properties": {
+                "id_link": {
+                    "type": "integer"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.SongPreview": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "existing_songs": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "group_created": {
+                    "type": "boolean"
+                },
+                "problems": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "song": {
+                    "$ref": "#/definitions/main.Song"
+                }
+            }
+        },
+        "main.SongRelation": {
+            "type": "object",
+            "properties": {
+                "id_original": {
+                    "type": "integer"
+                },
+                "id_relation": {
+                    "type": "integer"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
                 }
             }
         },
@@ -420,6 +3770,168 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "main.SongTextPage": {
+            "type": "object",
+            "properties": {
+                "has_next": {
+                    "type": "boolean"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "sections": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.LyricSection"
+                    }
+                },
+                "text": {
+                    "type": "string"
+                },
+                "total_chars": {
+                    "type": "integer"
+                },
+                "total_lines": {
+                    "type": "integer"
+                },
+                "total_pages": {
+                    "type": "integer"
+                },
+                "total_verses": {
+                    "type": "integer"
+                },
+                "translation_language": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.SongVersion": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "id_song": {
+                    "type": "integer"
+                },
+                "song": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.SongVersion"
+                    }
+                }
+            }
+        },
+        "main.SyncedLine": {
+            "type": "object",
+            "properties": {
+                "end_ms": {
+                    "type": "integer"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "time_ms": {
+                    "type": "integer"
+                },
+                "words": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.SyncedWord"
+                    }
+                },
+                "words_end_ms": {
+                    "description": "WordsEndMs is when the last word ends, given by a trailing word timestamp in enhanced LRC.",
+                    "type": "integer"
+                }
+            }
+        },
+        "main.SyncedLyrics": {
+            "type": "object",
+            "properties": {
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.SyncedLine"
+                    }
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "main.SyncedWord": {
+            "type": "object",
+            "properties": {
+                "text": {
+                    "type": "string"
+                },
+                "time_ms": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.VerseRhyme": {
+            "type": "object",
+            "properties": {
+                "index": {
+                    "type": "integer"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "pattern": {
+                    "type": "string"
+                },
+                "scheme": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.WordCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "word": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.fieldSources": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
         }
     }
 }`
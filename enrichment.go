@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errEnrichmentNotConfigured = errors.New("enrichment provider not configured")
+	errSongDetailsNotFound     = errors.New("song details not found")
+	errCircuitOpen             = errors.New("external API circuit breaker is open")
+	errMalformedResponse       = errors.New("malformed external API response")
+)
+
+// upstreamStatusError is returned when the external API answers with an unexpected status code.
+// RetryAfter is set when a 429 or 503 answer said how long to wait.
+type upstreamStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("external API returned status %d", e.StatusCode)
+}
+
+// enrichmentClient calls an external music-info API with per-attempt timeouts,
+// bounded retries with jittered exponential backoff, a circuit breaker and an outbound rate limit.
+type enrichmentClient struct {
+	name          string
+	baseURL       string
+	fieldMap      map[string]string
+	httpClient    *http.Client
+	timeout       time.Duration
+	maxRetries    int
+	baseBackoff   time.Duration
+	maxBackoff    time.Duration
+	maxRetryAfter time.Duration
+	dateFormats   []dateFormat
+	breaker       *circuitBreaker
+	limiter       *outboundLimiter
+}
+
+// newEnrichmentClientFromEnv configures a client from the settings starting with prefix,
+// e.g. EXTERNAL_API_URL and EXTERNAL_API_TIMEOUT for prefix "EXTERNAL_API".
+func newEnrichmentClientFromEnv(name, prefix string) *enrichmentClient {
+	client := &enrichmentClient{
+		name:          name,
+		baseURL:       os.Getenv(prefix + "_URL"),
+		fieldMap:      parseFieldMap(os.Getenv(prefix + "_FIELD_MAP")),
+		httpClient:    &http.Client{},
+		timeout:       envDuration(prefix+"_TIMEOUT", 5*time.Second),
+		maxRetries:    envInt(prefix+"_RETRIES", 2),
+		baseBackoff:   envDuration(prefix+"_BACKOFF", 200*time.Millisecond),
+		maxBackoff:    envDuration(prefix+"_MAX_BACKOFF", 2*time.Second),
+		maxRetryAfter: envDuration(prefix+"_MAX_RETRY_AFTER", 30*time.Second),
+		breaker: newCircuitBreaker(
+			envInt(prefix+"_BREAKER_THRESHOLD", 5),
+			envDuration(prefix+"_BREAKER_COOLDOWN", 30*time.Second),
+		),
+		limiter: newOutboundLimiter(name,
+			envFloat(prefix+"_RATE_LIMIT", 0),
+			envInt(prefix+"_BURST", 1),
+			envInt(prefix+"_MAX_IN_FLIGHT", 0),
+		),
+	}
+	formats, err := parseDateFormats(os.Getenv(prefix + "_DATE_FORMATS"))
+	if err != nil {
+		if os.Getenv(prefix+"_DATE_FORMATS") != "" {
+			slog.Warn("Invalid date formats, using defaults", "name", prefix+"_DATE_FORMATS", "error", err)
+		}
+		formats, _ = parseDateFormats(defaultDateFormats)
+	}
+	client.dateFormats = formats
+
+	slog.Info("Enrichment client configured", "provider", name,
+		"url", client.baseURL, "timeout", client.timeout, "retries", client.maxRetries,
+		"rate_limit", client.limiter.rate, "max_in_flight", client.limiter.maxInFlight)
+	return client
+}
+
+// parseFieldMap reads mappings from our field names to JSON paths in the upstream response, like
+// "release_date=released,text=data.lyrics[0].body". Unmapped fields are read from the top level under their own name.
+func parseFieldMap(value string) map[string]string {
+	fieldMap := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		ours, theirs, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		ours, theirs = strings.TrimSpace(ours), strings.TrimSpace(theirs)
+		if !ok || !isDetailField(ours) || !validJSONPath(theirs) {
+			if strings.TrimSpace(pair) != "" {
+				slog.Warn("Ignoring malformed field mapping", "mapping", pair)
+			}
+			continue
+		}
+		fieldMap[ours] = theirs
+	}
+	return fieldMap
+}
+
+func isDetailField(name string) bool {
+	for _, field := range detailFields {
+		if field.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonPathSegments splits "$.data.tracks[0].title" into "data", "tracks", "0", "title".
+func jsonPathSegments(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	return strings.Split(path, ".")
+}
+
+func validJSONPath(path string) bool {
+	for _, segment := range jsonPathSegments(path) {
+		if segment == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveJSONPath walks a decoded JSON document; numeric segments index arrays.
+func resolveJSONPath(doc interface{}, path string) (interface{}, bool) {
+	for _, segment := range jsonPathSegments(path) {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func (c *enrichmentClient) Name() string {
+	return c.name
+}
+
+// Fetch looks up details of a song. It gives up when ctx is cancelled, so a client
+// disconnecting from our API also stops the upstream call.
+func (c *enrichmentClient) Fetch(ctx context.Context, group, song string) (SongDetail, error) {
+	if c.baseURL == "" {
+		return SongDetail{}, errEnrichmentNotConfigured
+	}
+
+	fullURL := fmt.Sprintf("%s?group=%s&song=%s",
+		c.baseURL,
+		url.QueryEscape(group),
+		url.QueryEscape(song),
+	)
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := c.backoff(attempt)
+			slog.Debug("Retrying external API call", "attempt", attempt, "delay", delay, "error", lastErr)
+			select {
+			case <-ctx.Done():
+				return SongDetail{}, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		if !c.breaker.allow() {
+			return SongDetail{}, errCircuitOpen
+		}
+
+		detail, err := c.fetchOnce(ctx, fullURL)
+		if err == nil {
+			c.breaker.success()
+			return detail, nil
+		}
+		if ctx.Err() != nil {
+			// A cancelled call says nothing about the upstream, but a trial must not stay pending.
+			c.breaker.release()
+			return SongDetail{}, ctx.Err()
+		}
+		if !isRetryable(err) {
+			// The upstream answered, so it is healthy even if the answer is a 404.
+			c.breaker.success()
+			return SongDetail{}, err
+		}
+
+		c.breaker.failure()
+		lastErr = err
+
+		// Waiting out a long Retry-After here would hold the caller; leave it to the queue.
+		var statusErr *upstreamStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > c.maxRetryAfter {
+			return SongDetail{}, err
+		}
+	}
+	return SongDetail{}, lastErr
+}
+
+func (c *enrichmentClient) fetchOnce(ctx context.Context, fullURL string) (SongDetail, error) {
+	// The timeout covers the request only, not the wait for the rate limiter.
+	release, err := c.limiter.acquire(ctx)
+	if err != nil {
+		return SongDetail{}, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return SongDetail{}, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return SongDetail{}, err
+	}
+	defer func() {
+		// Drain so the connection can be reused.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return SongDetail{}, errSongDetailsNotFound
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		c.limiter.throttled.Add(1)
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		if retryAfter > 0 {
+			// Longer waits are left to the queue, which reschedules the job by Retry-After; pausing
+			// for them would hold every synchronous caller just as long.
+			c.limiter.pause(min(retryAfter, c.maxRetryAfter))
+		}
+		return SongDetail{}, &upstreamStatusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter}
+	case resp.StatusCode != http.StatusOK:
+		return SongDetail{}, &upstreamStatusError{StatusCode: resp.StatusCode}
+	}
+
+	var doc interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return SongDetail{}, fmt.Errorf("%w: %v", errMalformedResponse, err)
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return SongDetail{}, fmt.Errorf("%w: expected a JSON object", errMalformedResponse)
+	}
+	return c.mapResponse(doc), nil
+}
+
+// mapResponse picks every field from its configured path. A value that doesn't fit is dropped
+// and reported in the detail's problems rather than failing the whole response.
+func (c *enrichmentClient) mapResponse(doc interface{}) SongDetail {
+	var detail SongDetail
+	for _, field := range detailFields {
+		path := field.name
+		if mapped, ok := c.fieldMap[field.name]; ok {
+			path = mapped
+		}
+		value, ok := resolveJSONPath(doc, path)
+		if !ok || value == nil {
+			continue
+		}
+
+		err := c.decodeField(&detail, field, value)
+		if err != nil {
+			slog.Warn("Ignoring field from external API", "provider", c.name, "field", field.name, "path", path, "error", err)
+			detail.Problems = append(detail.Problems, fmt.Sprintf("%s (%s): %v", field.name, path, err))
+		}
+	}
+	return detail
+}
+
+// decodeField stores one upstream value, letting SongDetail's own JSON tags decide how it is read.
+func (c *enrichmentClient) decodeField(detail *SongDetail, field detailField, value interface{}) error {
+	if field.name == "release_date" {
+		var date string
+		switch v := value.(type) {
+		case string:
+			date = v
+		case float64:
+			date = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Errorf("expected a date, got %s", jsonTypeName(value))
+		}
+		normalized, err := normalizeDate(c.dateFormats, date)
+		if err != nil {
+			return err
+		}
+		value = normalized
+	}
+
+	raw, err := json.Marshal(map[string]interface{}{field.name: value})
+	if err != nil {
+		return err
+	}
+	// Decode into a scratch value so a failed field leaves nothing half-set behind.
+	var decoded SongDetail
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("expected %s, got %s", jsonKindName(typeErr.Type), typeErr.Value)
+		}
+		return err
+	}
+	field.copy(detail, &decoded)
+	return nil
+}
+
+func jsonKindName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "number"
+	default:
+		return t.Kind().String()
+	}
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case bool:
+		return "bool"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// backoff returns a random delay up to baseBackoff*2^(attempt-1), capped at maxBackoff.
+func (c *enrichmentClient) backoff(attempt int) time.Duration {
+	ceiling := c.baseBackoff << (attempt - 1)
+	if ceiling > c.maxBackoff || ceiling <= 0 {
+		ceiling = c.maxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling))) + 1
+}
+
+// isRetryable reports whether another attempt may succeed: transport errors, timeouts, 429 and 5xx responses.
+func isRetryable(err error) bool {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, errSongDetailsNotFound) && !errors.Is(err, errMalformedResponse) &&
+		!errors.Is(err, errEnrichmentNotConfigured)
+}
+
+// circuitBreaker stops calls to the external API after threshold consecutive failures
+// and lets a single trial call through once cooldown has passed.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold < 1 || b.failures < b.threshold {
+		return true
+	}
+	if time.Since(b.openedAt) < b.cooldown || b.trial {
+		return false
+	}
+	b.trial = true
+	slog.Info("Circuit breaker half-open, sending trial request")
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures >= b.threshold && b.threshold > 0 {
+		slog.Info("Circuit breaker closed")
+	}
+	b.failures = 0
+	b.trial = false
+}
+
+// release ends a call that never got an answer, like one cancelled by its caller, without
+// counting it either way. A half-open breaker lets the next call be the trial.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		slog.Warn("Circuit breaker open", "failures", b.failures, "cooldown", b.cooldown)
+	}
+}
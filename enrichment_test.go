@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerCancelledTrial(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(c *enrichmentClient)
+	}{
+		{"cancelled request", func(c *enrichmentClient) {}},
+		{"cancelled while rate limited", func(c *enrichmentClient) { c.limiter.pause(time.Second) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var healthy atomic.Bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !healthy.Load() {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Write([]byte(`{"text": "Hello"}`))
+			}))
+			defer server.Close()
+
+			client := &enrichmentClient{
+				name:          "test",
+				baseURL:       server.URL,
+				httpClient:    server.Client(),
+				timeout:       time.Second,
+				maxRetryAfter: time.Second,
+				breaker:       newCircuitBreaker(1, 10*time.Millisecond),
+				limiter:       newOutboundLimiter("test", 0, 1, 0),
+			}
+
+			if _, err := client.Fetch(context.Background(), "group", "song"); err == nil {
+				t.Fatal("expected the failing upstream to open the breaker")
+			}
+			time.Sleep(20 * time.Millisecond)
+
+			// The half-open trial is cancelled before it gets an answer.
+			tc.setup(client)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := client.Fetch(ctx, "group", "song"); err != context.Canceled {
+				t.Fatalf("cancelled trial: got %v, want %v", err, context.Canceled)
+			}
+
+			client.limiter.pausedUntil = time.Time{}
+			healthy.Store(true)
+			detail, err := client.Fetch(context.Background(), "group", "song")
+			if err != nil {
+				t.Fatalf("next trial: %v", err)
+			}
+			if detail.Lyrics != "Hello" {
+				t.Fatalf("next trial: got lyrics %q", detail.Lyrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envInt reads an integer setting, falling back to def when it is unset or malformed.
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid integer setting, using default", "name", name, "value", value, "default", def)
+		return def
+	}
+	return parsed
+}
+
+// envDuration reads a duration setting such as "5s" or "250ms", falling back to def when it is unset or malformed.
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid duration setting, using default", "name", name, "value", value, "default", def)
+		return def
+	}
+	return parsed
+}
+
+// envFloat reads a decimal setting, falling back to def when it is unset or malformed.
+func envFloat(name string, def float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("Invalid decimal setting, using default", "name", name, "value", value, "default", def)
+		return def
+	}
+	return parsed
+}
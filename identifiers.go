@@ -0,0 +1,510 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const providerMusicBrainz = "musicbrainz"
+
+var (
+	isrcPattern     = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$`)
+	iswcPattern     = regexp.MustCompile(`^T[0-9]{10}$`)
+	mbidPattern     = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	providerPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,31}$`)
+)
+
+// normalizeISRC strips separators and validates the CC-XXX-YY-NNNNN layout.
+func normalizeISRC(isrc string) (string, error) {
+	isrc = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isrc))
+	if !isrcPattern.MatchString(isrc) {
+		return "", fmt.Errorf("invalid ISRC %q", isrc)
+	}
+	return isrc, nil
+}
+
+// normalizeISWC strips separators and validates the T-DDD.DDD.DDD-C layout and its check digit.
+func normalizeISWC(iswc string) (string, error) {
+	iswc = strings.ToUpper(strings.NewReplacer("-", "", ".", "", " ", "").Replace(iswc))
+	if !iswcPattern.MatchString(iswc) {
+		return "", fmt.Errorf("invalid ISWC %q", iswc)
+	}
+
+	sum := 1
+	for i := 1; i <= 9; i++ {
+		sum += int(iswc[i]-'0') * i
+	}
+	if check := (10 - sum%10) % 10; int(iswc[10]-'0') != check {
+		return "", fmt.Errorf("invalid ISWC check digit in %q", iswc)
+	}
+	return iswc, nil
+}
+
+// normalizeExternalID validates a provider key and its ID; MusicBrainz IDs must be UUIDs.
+func normalizeExternalID(provider, id string) (string, string, error) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if !providerPattern.MatchString(provider) {
+		return "", "", fmt.Errorf("invalid provider %q", provider)
+	}
+
+	id = strings.TrimSpace(id)
+	if provider == providerMusicBrainz {
+		id = strings.ToLower(id)
+		if !mbidPattern.MatchString(id) {
+			return "", "", fmt.Errorf("invalid MusicBrainz ID %q", id)
+		}
+	}
+	if id == "" || len(id) > 255 {
+		return "", "", fmt.Errorf("external ID must be 1 to 255 characters")
+	}
+	return provider, id, nil
+}
+
+// externalIDTable describes where external IDs of songs or groups are stored.
+type externalIDTable struct {
+	table  string
+	column string
+}
+
+var (
+	songExternalIDs  = externalIDTable{table: "song_external_ids", column: "id_song"}
+	groupExternalIDs = externalIDTable{table: "group_external_ids", column: "id_group"}
+)
+
+func (t externalIDTable) list(id int) (map[string]string, error) {
+	var ids []ExternalID
+	query := fmt.Sprintf("SELECT provider, external_id FROM %s WHERE %s = $1 ORDER BY provider", t.table, t.column)
+	if err := db.Select(&ids, query, id); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(ids))
+	for _, externalID := range ids {
+		result[externalID.Provider] = externalID.ExternalID
+	}
+	return result, nil
+}
+
+func (t externalIDTable) lookup(provider, externalID string) (int, error) {
+	var id int
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE provider = $1 AND external_id = $2", t.column, t.table)
+	err := db.Get(&id, query, provider, externalID)
+	return id, err
+}
+
+func (t externalIDTable) set(id int, provider, externalID string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (%[2]s, provider, external_id) VALUES ($1, $2, $3)
+		ON CONFLICT (%[2]s, provider) DO UPDATE SET external_id = EXCLUDED.external_id`, t.table, t.column)
+	_, err := db.Exec(query, id, provider, externalID)
+	return err
+}
+
+func (t externalIDTable) delete(id int, provider string) (bool, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND provider = $2", t.table, t.column)
+	result, err := db.Exec(query, id, provider)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+const songByIDQuery = `
+	SELECT ` + songColumnsSQL + `
+	FROM songs s
+	INNER JOIN musicGroups g ON s.id_group = g.id_group
+	WHERE s.id_song = $1`
+
+// fetchSongWithIDs loads a song together with its external IDs.
+func fetchSongWithIDs(idSong int) (Song, error) {
+	var song Song
+	if err := db.Get(&song, songByIDQuery, idSong); err != nil {
+		return song, err
+	}
+
+	var err error
+	if song.ExternalIDs, err = songExternalIDs.list(idSong); err != nil {
+		return song, err
+	}
+
+	links, err := songLinks([]int64{int64(idSong)})
+	song.Links = links[idSong]
+	return song, err
+}
+
+// fetchGroupWithIDs loads a group together with its external IDs.
+func fetchGroupWithIDs(idGroup int) (Group, error) {
+	var group Group
+	query := `SELECT id_group, groupName AS "groupName" FROM musicGroups WHERE id_group = $1`
+	if err := db.Get(&group, query, idGroup); err != nil {
+		return group, err
+	}
+
+	var err error
+	group.ExternalIDs, err = groupExternalIDs.list(idGroup)
+	return group, err
+}
+
+func writeSong(w http.ResponseWriter, idSong int) {
+	song, err := fetchSongWithIDs(idSong)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to fetch song", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(song)
+}
+
+func writeGroup(w http.ResponseWriter, idGroup int) {
+	group, err := fetchGroupWithIDs(idGroup)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Group not found", "id_group", idGroup)
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch group", "error", err)
+		http.Error(w, "Failed to fetch group", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
+
+// @Summary Song by ISRC
+// @Description Find a song by its International Standard Recording Code. Hyphens are optional.
+// @Tags identifiers
+// @Accept  json
+// @Produce  json
+// @Param isrc path string true "ISRC, e.g. US-RC1-76-07839"
+// @Success 200 {object} Song "The song"
+// @Failure 400 {string} string "Invalid ISRC"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch song"
+// @Router /songs/by-isrc/{isrc} [get]
+func getSongByISRC(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getSongByISRC")
+
+	isrc, err := normalizeISRC(mux.Vars(r)["isrc"])
+	if err != nil {
+		slog.Warn("Invalid ISRC", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var idSong int
+	err = db.Get(&idSong, "SELECT id_song FROM songs WHERE isrc = $1", isrc)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "isrc", isrc)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to fetch song", http.StatusInternalServerError)
+		return
+	}
+
+	writeSong(w, idSong)
+	slog.Debug("Song fetched by ISRC", "isrc", isrc, "id_song", idSong)
+}
+
+// @Summary Songs by ISWC
+// @Description Find every recording of a musical work by its International Standard Musical Work Code.
+// @Tags identifiers
+// @Accept  json
+// @Produce  json
+// @Param iswc path string true "ISWC, e.g. T-034.524.680-1"
+// @Success 200 {array} Song "Recordings of the work"
+// @Failure 400 {string} string "Invalid ISWC"
+// @Failure 404 {string} string "No songs found"
+// @Failure 500 {string} string "Failed to fetch songs"
+// @Router /songs/by-iswc/{iswc} [get]
+func getSongsByISWC(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getSongsByISWC")
+
+	iswc, err := normalizeISWC(mux.Vars(r)["iswc"])
+	if err != nil {
+		slog.Warn("Invalid ISWC", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var songs []Song
+	query := `
+		SELECT ` + songColumnsSQL + `
+		FROM songs s
+		INNER JOIN musicGroups g ON s.id_group = g.id_group
+		WHERE s.iswc = $1
+		ORDER BY s.id_song`
+	if err := db.Select(&songs, query, iswc); err != nil {
+		slog.Error("Failed to fetch songs", "error", err)
+		http.Error(w, "Failed to fetch songs", http.StatusInternalServerError)
+		return
+	}
+	if len(songs) == 0 {
+		slog.Warn("No songs found", "iswc", iswc)
+		http.Error(w, "No songs found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(songs)
+
+	slog.Debug("Songs fetched by ISWC", "iswc", iswc, "count", len(songs))
+}
+
+// @Summary Song by external ID
+// @Description Find a song by an external provider ID, e.g. provider=musicbrainz and a recording MBID.
+// @Tags identifiers
+// @Accept  json
+// @Produce  json
+// @Param provider query string true "Provider key"
+// @Param id query string true "ID at the provider"
+// @Success 200 {object} Song "The song"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch song"
+// @Router /songs/by-external-id [get]
+func getSongByExternalID(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getSongByExternalID")
+
+	provider, externalID, err := normalizeExternalID(r.URL.Query().Get("provider"), r.URL.Query().Get("id"))
+	if err != nil {
+		slog.Warn("Invalid external ID", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	idSong, err := songExternalIDs.lookup(provider, externalID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "provider", provider, "id", externalID)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to fetch song", http.StatusInternalServerError)
+		return
+	}
+
+	writeSong(w, idSong)
+	slog.Debug("Song fetched by external ID", "provider", provider, "id", externalID, "id_song", idSong)
+}
+
+// @Summary Group by external ID
+// @Description Find a group by an external provider ID, e.g. provider=musicbrainz and an artist MBID.
+// @Tags identifiers
+// @Accept  json
+// @Produce  json
+// @Param provider query string true "Provider key"
+// @Param id query string true "ID at the provider"
+// @Success 200 {object} Group "The group"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Group not found"
+// @Failure 500 {string} string "Failed to fetch group"
+// @Router /groups/by-external-id [get]
+func getGroupByExternalID(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getGroupByExternalID")
+
+	provider, externalID, err := normalizeExternalID(r.URL.Query().Get("provider"), r.URL.Query().Get("id"))
+	if err != nil {
+		slog.Warn("Invalid external ID", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	idGroup, err := groupExternalIDs.lookup(provider, externalID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Group not found", "provider", provider, "id", externalID)
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch group", "error", err)
+		http.Error(w, "Failed to fetch group", http.StatusInternalServerError)
+		return
+	}
+
+	writeGroup(w, idGroup)
+	slog.Debug("Group fetched by external ID", "provider", provider, "id", externalID, "id_group", idGroup)
+}
+
+// @Summary Set a song external ID
+// @Description Attach or replace the ID of a song at an external provider. MusicBrainz IDs must be valid UUIDs.
+// @Tags identifiers
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param provider path string true "Provider key, e.g. musicbrainz"
+// @Param input body ExternalID true "ID at the provider"
+// @Success 200 {object} Song "The song with its external IDs"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Song not found"
+// @Failure 409 {string} string "External ID already used by another song"
+// @Failure 500 {string} string "Failed to set external ID"
+// @Router /songs/{id_song}/external-ids/{provider} [put]
+func setSongExternalID(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request setSongExternalID")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	if !setExternalID(w, r, songExternalIDs, idSong) {
+		return
+	}
+
+	writeSong(w, idSong)
+	slog.Debug("Song external ID set successfully", "id_song", idSong)
+}
+
+// @Summary Delete a song external ID
+// @Description Remove the ID of a song at an external provider.
+// @Tags identifiers
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param provider path string true "Provider key"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "External ID not found"
+// @Failure 500 {string} string "Failed to delete external ID"
+// @Router /songs/{id_song}/external-ids/{provider} [delete]
+func deleteSongExternalID(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request deleteSongExternalID")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	deleteExternalID(w, r, songExternalIDs, idSong)
+}
+
+// @Summary Set a group external ID
+// @Description Attach or replace the ID of a group at an external provider. MusicBrainz IDs must be valid UUIDs.
+// @Tags identifiers
+// @Accept  json
+// @Produce  json
+// @Param id_group path int true "ID of the group"
+// @Param provider path string true "Provider key, e.g. musicbrainz"
+// @Param input body ExternalID true "ID at the provider"
+// @Success 200 {object} Group "The group with its external IDs"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Group not found"
+// @Failure 409 {string} string "External ID already used by another group"
+// @Failure 500 {string} string "Failed to set external ID"
+// @Router /groups/{id_group}/external-ids/{provider} [put]
+func setGroupExternalID(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request setGroupExternalID")
+
+	idGroup, ok := pathID(w, r, "id_group")
+	if !ok {
+		return
+	}
+	if !setExternalID(w, r, groupExternalIDs, idGroup) {
+		return
+	}
+
+	writeGroup(w, idGroup)
+	slog.Debug("Group external ID set successfully", "id_group", idGroup)
+}
+
+// @Summary Delete a group external ID
+// @Description Remove the ID of a group at an external provider.
+// @Tags identifiers
+// @Accept  json
+// @Produce  json
+// @Param id_group path int true "ID of the group"
+// @Param provider path string true "Provider key"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "External ID not found"
+// @Failure 500 {string} string "Failed to delete external ID"
+// @Router /groups/{id_group}/external-ids/{provider} [delete]
+func deleteGroupExternalID(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request deleteGroupExternalID")
+
+	idGroup, ok := pathID(w, r, "id_group")
+	if !ok {
+		return
+	}
+	deleteExternalID(w, r, groupExternalIDs, idGroup)
+}
+
+// setExternalID stores the external ID from the request body, writing an error response and returning false on failure.
+func setExternalID(w http.ResponseWriter, r *http.Request, t externalIDTable, id int) bool {
+	var input ExternalID
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.Warn("Invalid JSON format", "error", err)
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return false
+	}
+
+	provider, externalID, err := normalizeExternalID(mux.Vars(r)["provider"], input.ExternalID)
+	if err != nil {
+		slog.Warn("Invalid external ID", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	err = t.set(id, provider, externalID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			slog.Warn("Owner of external ID not found", t.column, id)
+			http.Error(w, "Not found", http.StatusNotFound)
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			slog.Warn("External ID already in use", "provider", provider, "id", externalID)
+			http.Error(w, "External ID already in use", http.StatusConflict)
+		default:
+			slog.Error("Failed to set external ID", "error", err)
+			http.Error(w, "Failed to set external ID", http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+func deleteExternalID(w http.ResponseWriter, r *http.Request, t externalIDTable, id int) {
+	provider := strings.ToLower(mux.Vars(r)["provider"])
+	if !providerPattern.MatchString(provider) {
+		slog.Warn("Invalid provider", "provider", provider)
+		http.Error(w, "Invalid provider", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := t.delete(id, provider)
+	if err != nil {
+		slog.Error("Failed to delete external ID", "error", err)
+		http.Error(w, "Failed to delete external ID", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		slog.Warn("External ID not found", t.column, id, "provider", provider)
+		http.Error(w, "External ID not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Debug("External ID deleted successfully", t.column, id, "provider", provider)
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Sources of songs.language: detected from the lyrics or set by hand, which detection never overrides.
+const (
+	languageDetected = "detected"
+	languageManual   = "manual"
+)
+
+// undeterminedLanguage is the BCP 47 tag for an unknown language, used for songs without a language.
+const undeterminedLanguage = "und"
+
+const (
+	// minDetectLetters is the fewest letters detection is attempted on.
+	minDetectLetters = 8
+	// minDetectTrigrams is the fewest distinct trigrams a Latin or Cyrillic text is scored on, so
+	// refrains like "la la la" stay undetermined.
+	minDetectTrigrams = 10
+	// minLanguageConfidence is the confidence below which no language is stored.
+	minLanguageConfidence = 0.5
+	// maxDetectTrigrams bounds the trigrams scored for long lyrics.
+	maxDetectTrigrams = 2000
+	// languageEvidence is the number of trigrams whose evidence is counted in full; longer texts are
+	// scaled down to it, so confidences stay comparable instead of saturating at 1.
+	languageEvidence = 60
+)
+
+// Sample texts the trigram profiles are trained on, one file per language named by its tag.
+//
+//go:embed langdata/*.txt
+var languageSamples embed.FS
+
+// scriptLanguages maps scripts that are written in a single language here to that language.
+var scriptLanguages = []struct {
+	script   *unicode.RangeTable
+	language string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// languageProfile holds the trigram log-probabilities of one language.
+type languageProfile struct {
+	language string
+	script   *unicode.RangeTable
+	logProb  map[string]float64
+	unseen   float64
+}
+
+var languageProfiles = loadLanguageProfiles()
+
+// languageGuess is a detected language with the probability it is right.
+type languageGuess struct {
+	Language   string
+	Confidence float64
+}
+
+// loadLanguageProfiles trains a profile from every embedded sample.
+func loadLanguageProfiles() []languageProfile {
+	files, err := languageSamples.ReadDir("langdata")
+	if err != nil {
+		panic(err)
+	}
+	var profiles []languageProfile
+	for _, file := range files {
+		data, err := languageSamples.ReadFile(path.Join("langdata", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		profiles = append(profiles, trainLanguageProfile(strings.TrimSuffix(file.Name(), ".txt"), string(data)))
+	}
+	return profiles
+}
+
+// trainLanguageProfile counts the trigrams of a sample with add-one smoothing.
+func trainLanguageProfile(language, sample string) languageProfile {
+	counts := map[string]int{}
+	total := 0
+	for _, trigram := range textTrigrams(sample) {
+		counts[trigram]++
+		total++
+	}
+	scripts, _ := scriptLetters(sample)
+	profile := languageProfile{language: language, script: dominantScript(scripts), logProb: make(map[string]float64, len(counts))}
+	denominator := float64(total + len(counts) + 1)
+	for trigram, count := range counts {
+		profile.logProb[trigram] = math.Log(float64(count+1) / denominator)
+	}
+	profile.unseen = math.Log(1 / denominator)
+	return profile
+}
+
+// textTrigrams returns the letter trigrams of the words of a text, each word padded with spaces
+// so its start and end count too.
+func textTrigrams(text string) []string {
+	var trigrams []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		runes := []rune(" " + strings.Trim(word, "'") + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams = append(trigrams, string(runes[i:i+3]))
+		}
+	}
+	return trigrams
+}
+
+// scriptLetters counts the letters of a text by script, among Latin, Cyrillic, Han, kana and those of scriptLanguages.
+func scriptLetters(text string) (map[*unicode.RangeTable]int, int) {
+	counts := map[*unicode.RangeTable]int{}
+	letters := 0
+	for _, r := range text {
+		if script := letterScript(r); script != nil {
+			counts[script]++
+			letters++
+		}
+	}
+	return counts, letters
+}
+
+// dominantScript returns the script with the most letters.
+func dominantScript(counts map[*unicode.RangeTable]int) *unicode.RangeTable {
+	var best *unicode.RangeTable
+	for script, count := range counts {
+		if best == nil || count > counts[best] {
+			best = script
+		}
+	}
+	return best
+}
+
+// letterScript returns the script of a letter, nil for other runes.
+func letterScript(r rune) *unicode.RangeTable {
+	if !unicode.IsLetter(r) {
+		return nil
+	}
+	for _, script := range []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic, unicode.Han, unicode.Hiragana, unicode.Katakana} {
+		if unicode.Is(script, r) {
+			return script
+		}
+	}
+	for _, s := range scriptLanguages {
+		if unicode.Is(s.script, r) {
+			return s.script
+		}
+	}
+	return nil
+}
+
+// detectLanguage guesses the language of a text, most likely first. Scripts used by one language
+// decide it by the share of their letters; Latin and Cyrillic texts are scored against the trigram
+// profiles. Texts too short to tell give no guesses.
+func detectLanguage(text string) []languageGuess {
+	counts, letters := scriptLetters(text)
+	if letters < minDetectLetters {
+		return nil
+	}
+	share := func(n int) float64 { return math.Round(float64(n)/float64(letters)*100) / 100 }
+
+	// Japanese mixes kana with Han characters; Han alone is Chinese.
+	kana := counts[unicode.Hiragana] + counts[unicode.Katakana]
+	if cjk := counts[unicode.Han] + kana; cjk*2 > letters {
+		if kana > 0 {
+			return []languageGuess{{"ja", share(cjk)}}
+		}
+		return []languageGuess{{"zh", share(cjk)}}
+	}
+	delete(counts, unicode.Han)
+	delete(counts, unicode.Hiragana)
+	delete(counts, unicode.Katakana)
+	script := dominantScript(counts)
+	for _, s := range scriptLanguages {
+		if s.script == script {
+			return []languageGuess{{s.language, share(counts[script])}}
+		}
+	}
+
+	var trigrams []string
+	distinct := map[string]bool{}
+	for _, trigram := range textTrigrams(text) {
+		if r := []rune(strings.TrimSpace(trigram)); len(r) > 0 && letterScript(r[0]) == script {
+			trigrams = append(trigrams, trigram)
+			distinct[trigram] = true
+		}
+	}
+	if len(distinct) < minDetectTrigrams {
+		return nil
+	}
+	if len(trigrams) > maxDetectTrigrams {
+		trigrams = trigrams[:maxDetectTrigrams]
+	}
+
+	scale := 1.0
+	if len(trigrams) > languageEvidence {
+		scale = float64(languageEvidence) / float64(len(trigrams))
+	}
+	var guesses []languageGuess
+	var scores []float64
+	best := math.Inf(-1)
+	for _, profile := range languageProfiles {
+		if profile.script != script {
+			continue
+		}
+		score := 0.0
+		for _, trigram := range trigrams {
+			if p, ok := profile.logProb[trigram]; ok {
+				score += p
+			} else {
+				score += profile.unseen
+			}
+		}
+		score *= scale
+		guesses = append(guesses, languageGuess{Language: profile.language})
+		scores = append(scores, score)
+		best = math.Max(best, score)
+	}
+	if len(guesses) == 0 {
+		return nil
+	}
+
+	// The posteriors follow from the scores with equal priors.
+	sum := 0.0
+	for i := range scores {
+		scores[i] = math.Exp(scores[i] - best)
+		sum += scores[i]
+	}
+	for i := range guesses {
+		guesses[i].Confidence = math.Round(scores[i]/sum*share(counts[script])*100) / 100
+	}
+	sort.SliceStable(guesses, func(i, j int) bool { return guesses[i].Confidence > guesses[j].Confidence })
+	return guesses
+}
+
+// lyricsLanguage returns the detected language of lyrics and its confidence, "" when it can't be told.
+// Section headers like [Chorus] are left out.
+func lyricsLanguage(lyrics string) (string, float64) {
+	var lines []string
+	for _, section := range parseLyricSections(lyrics) {
+		lines = append(lines, section.Lines...)
+	}
+	guesses := detectLanguage(strings.Join(lines, "\n"))
+	if len(guesses) == 0 || guesses[0].Confidence < minLanguageConfidence {
+		return "", 0
+	}
+	return guesses[0].Language, guesses[0].Confidence
+}
+
+// updateLyricsLanguage detects the language of a song's current lyrics and stores it, unless the
+// language was set by hand. An undetermined language clears the detected one.
+func updateLyricsLanguage(tx *sqlx.Tx, idSong int) error {
+	var song struct {
+		Lyrics string  `db:"lyrics"`
+		Source *string `db:"language_source"`
+	}
+	err := tx.Get(&song, "SELECT COALESCE(lyrics, '') AS lyrics, language_source FROM songs WHERE id_song = $1", idSong)
+	if err != nil {
+		return err
+	}
+	if song.Source != nil && *song.Source == languageManual {
+		return nil
+	}
+
+	language, confidence := lyricsLanguage(song.Lyrics)
+	_, err = tx.Exec(`
+		UPDATE songs
+		SET language = NULLIF($2, ''), language_confidence = NULLIF($3::real, 0), language_source = $4
+		WHERE id_song = $1`,
+		idSong, language, confidence, languageDetected)
+	return err
+}
+
+// backfillLyricsLanguages detects the language of songs stored before detection existed.
+func backfillLyricsLanguages() {
+	var ids []int
+	if err := db.Select(&ids, "SELECT id_song FROM songs WHERE language_source IS NULL"); err != nil {
+		slog.Error("Failed to find songs without a language", "error", err)
+		return
+	}
+
+	for _, idSong := range ids {
+		tx, err := db.Beginx()
+		if err != nil {
+			slog.Error("Failed to begin transaction", "error", err)
+			return
+		}
+		if err := updateLyricsLanguage(tx, idSong); err != nil {
+			tx.Rollback()
+			slog.Error("Failed to detect lyrics language", "id_song", idSong, "error", err)
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			slog.Error("Failed to commit lyrics language", "id_song", idSong, "error", err)
+		}
+	}
+	if len(ids) > 0 {
+		slog.Info("Lyrics languages detected", "songs", len(ids))
+	}
+}
+
+// @Summary Song counts by lyrics language
+// @Description Count the songs per language of their lyrics, most frequent first; songs whose language is unknown are counted under und. Takes the filters of GET /songs except language, so the counts tell how many songs each language would select.
+// @Tags songs
+// @Produce  json
+// @Param group query string false "Filter by group name"
+// @Param song query string false "Filter by song name"
+// @Param release_date query string false "Filter by release date (YYYY, YYYY-MM or YYYY-MM-DD)"
+// @Param text query string false "Filter by text"
+// @Param explicit query bool false "Filter by explicit-content flag"
+// @Success 200 {array} LanguageFacet "Songs per language"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 500 {string} string "Failed to count songs"
+// @Router /songs/facets/language [get]
+func getLanguageFacets(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to getLanguageFacets")
+
+	filters, args, err := songFilters(r.URL.Query(), "language")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := `
+		SELECT COALESCE(s.language, '` + undeterminedLanguage + `') AS language, COUNT(*) AS count
+		FROM songs s
+		INNER JOIN musicGroups g ON s.id_group = g.id_group
+		WHERE 1=1` + filters + `
+		GROUP BY 1
+		ORDER BY count DESC, language`
+	facets := []LanguageFacet{}
+	if err := db.Select(&facets, query, args...); err != nil {
+		slog.Error("Failed to count songs by language", "error", err)
+		http.Error(w, "Failed to count songs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(facets)
+
+	slog.Debug("Language facets counted", "languages", len(facets))
+}
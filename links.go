@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	providerYouTube    = "youtube"
+	providerSpotify    = "spotify"
+	providerBandcamp   = "bandcamp"
+	providerSoundCloud = "soundcloud"
+	providerGeneric    = "generic"
+
+	maxLinkLength = 2048
+)
+
+var linkProviders = map[string]bool{
+	providerYouTube:    true,
+	providerSpotify:    true,
+	providerBandcamp:   true,
+	providerSoundCloud: true,
+	providerGeneric:    true,
+}
+
+// primaryLinkSQL selects the first link of song s, kept as the single "link" field of Song and SongDetail.
+const primaryLinkSQL = `COALESCE((SELECT l.url FROM song_links l WHERE l.id_song = s.id_song ORDER BY l.id_link LIMIT 1), '') AS link`
+
+// parseLink validates an http(s) URL and detects which provider hosts it.
+func parseLink(rawURL string) (string, string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" || len(rawURL) > maxLinkLength {
+		return "", "", fmt.Errorf("link must be 1 to %d characters", maxLinkLength)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", "", fmt.Errorf("invalid link %q, expected an http or https URL", rawURL)
+	}
+
+	return u.String(), detectLinkProvider(u.Hostname()), nil
+}
+
+func detectLinkProvider(host string) string {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	hasDomain := func(domain string) bool {
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+
+	switch {
+	case hasDomain("youtube.com"), hasDomain("youtu.be"):
+		return providerYouTube
+	case hasDomain("spotify.com"), host == "spotify.link":
+		return providerSpotify
+	case hasDomain("bandcamp.com"):
+		return providerBandcamp
+	case hasDomain("soundcloud.com"), host == "snd.sc":
+		return providerSoundCloud
+	default:
+		return providerGeneric
+	}
+}
+
+// insertLink adds a validated link to a song, ignoring URLs the song already has.
+func insertLink(tx *sqlx.Tx, idSong int, rawURL string) error {
+	link, provider, err := parseLink(rawURL)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO song_links (id_song, url, provider) VALUES ($1, $2, $3) ON CONFLICT (id_song, url) DO NOTHING",
+		idSong, link, provider,
+	)
+	return err
+}
+
+// replacePrimaryLink overwrites the song's first link, or adds one if it has none. A URL the song
+// already has as another link fails with a unique violation.
+func replacePrimaryLink(tx *sqlx.Tx, idSong int, rawURL string) error {
+	link, provider, err := parseLink(rawURL)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE song_links SET url = $2, provider = $3
+		WHERE id_link = (SELECT id_link FROM song_links WHERE id_song = $1 ORDER BY id_link LIMIT 1)`
+	result, err := tx.Exec(query, idSong, link, provider)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+		return nil
+	}
+
+	_, err = tx.Exec("INSERT INTO song_links (id_song, url, provider) VALUES ($1, $2, $3)", idSong, link, provider)
+	return err
+}
+
+// @Summary Song links
+// @Description Get every link of a song with its detected provider.
+// @Tags links
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Success 200 {array} SongLink "Links of the song"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch links"
+// @Router /songs/{id_song}/links [get]
+func getSongLinks(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getSongLinks")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var exists bool
+	if err := db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM songs WHERE id_song = $1)", idSong); err != nil {
+		slog.Error("Failed to fetch links", "error", err)
+		http.Error(w, "Failed to fetch links", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		slog.Warn("Song not found", "id_song", idSong)
+		http.Error(w, "Song not found", http.StatusNotFound)
+		return
+	}
+
+	links := []SongLink{}
+	query := `SELECT id_link, id_song, url, provider FROM song_links WHERE id_song = $1 ORDER BY id_link`
+	if err := db.Select(&links, query, idSong); err != nil {
+		slog.Error("Failed to fetch links", "error", err)
+		http.Error(w, "Failed to fetch links", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+
+	slog.Debug("Song links fetched successfully", "id_song", idSong, "count", len(links))
+}
+
+// @Summary Add a song link
+// @Description Attach a link to a song. The provider (youtube, spotify, bandcamp, soundcloud, generic) is detected from the URL.
+// @Tags links
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param input body SongLink true "URL of the link"
+// @Success 201 {object} SongLink "The created link"
+// @Failure 400 {string} string "Invalid URL"
+// @Failure 404 {string} string "Song not found"
+// @Failure 409 {string} string "Song already has this link"
+// @Failure 500 {string} string "Failed to add link"
+// @Router /songs/{id_song}/links [post]
+func addSongLink(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request addSongLink")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var link SongLink
+	if err := json.NewDecoder(r.Body).Decode(&link); err != nil {
+		slog.Warn("Invalid JSON format", "error", err)
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	link.SongID = idSong
+	link.URL, link.Provider, err = parseLink(link.URL)
+	if err != nil {
+		slog.Warn("Invalid link", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = db.QueryRow(
+		"INSERT INTO song_links (id_song, url, provider) VALUES ($1, $2, $3) RETURNING id_link",
+		link.SongID, link.URL, link.Provider,
+	).Scan(&link.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			slog.Warn("Link already exists", "id_song", idSong, "url", link.URL)
+			http.Error(w, "Song already has this link", http.StatusConflict)
+		default:
+			slog.Error("Failed to add link", "error", err)
+			http.Error(w, "Failed to add link", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+
+	slog.Debug("Song link added successfully", "id_song", idSong, "provider", link.Provider)
+}
+
+// @Summary Delete a song link
+// @Description Remove a link from a song.
+// @Tags links
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param id_link path int true "ID of the link"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Link not found"
+// @Failure 500 {string} string "Failed to delete link"
+// @Router /songs/{id_song}/links/{id_link} [delete]
+func deleteSongLink(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request deleteSongLink")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	idLink, ok := pathID(w, r, "id_link")
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM song_links WHERE id_link = $1 AND id_song = $2", idLink, idSong)
+	if err != nil {
+		slog.Error("Failed to delete link", "error", err)
+		http.Error(w, "Failed to delete link", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		slog.Warn("Link not found", "id_song", idSong, "id_link", idLink)
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Debug("Song link deleted successfully", "id_song", idSong, "id_link", idLink)
+}
+
+// songLinks loads the links of the given songs grouped by song ID.
+func songLinks(ids []int64) (map[int][]SongLink, error) {
+	var links []SongLink
+	query := `SELECT id_link, id_song, url, provider FROM song_links WHERE id_song = ANY($1) ORDER BY id_link`
+	if err := db.Select(&links, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int][]SongLink)
+	for _, link := range links {
+		result[link.SongID] = append(result[link.SongID], link)
+	}
+	return result, nil
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// maxLRCSize bounds an uploaded LRC file.
+	maxLRCSize = 1 << 20
+	// maxKaraokeWindow bounds how many lines around the active one a karaoke request may ask for.
+	maxKaraokeWindow = 50
+)
+
+var (
+	// lrcTimeRe matches a line timestamp like [01:23.45], [01:23.456] or [01:23].
+	lrcTimeRe = regexp.MustCompile(`^\[(\d{1,3}):(\d{2})(?:[.:](\d{1,3}))?\]`)
+	// lrcTagRe matches an ID tag like [ar:Muse] or [offset:+250].
+	lrcTagRe = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]$`)
+	// lrcWordTimeRe matches an enhanced LRC word timestamp like <01:23.45>.
+	lrcWordTimeRe = regexp.MustCompile(`<(\d{1,3}):(\d{2})(?:[.:](\d{1,3}))?>`)
+)
+
+// lrcMillis converts the captured minutes, seconds and fraction of a timestamp to milliseconds.
+func lrcMillis(minutes, seconds, fraction string) (int, error) {
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.Atoi(seconds)
+	if s >= 60 {
+		return 0, fmt.Errorf("seconds out of range in %s:%s", minutes, seconds)
+	}
+	ms := 0
+	if fraction != "" {
+		// ".5" is half a second, ".05" five hundredths, ".005" five thousandths.
+		ms, _ = strconv.Atoi((fraction + "00")[:3])
+	}
+	return (m*60+s)*1000 + ms, nil
+}
+
+// formatLRCTime writes a timestamp in hundredths of a second, or in thousandths when it has them,
+// so [01:23.456] survives a round trip.
+func formatLRCTime(ms int) string {
+	ms = max(ms, 0)
+	if ms%10 != 0 {
+		return fmt.Sprintf("%02d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+	}
+	return fmt.Sprintf("%02d:%02d.%02d", ms/60000, ms/1000%60, ms%1000/10)
+}
+
+// parseLRC reads LRC lyrics, including enhanced word timestamps. ID tags are kept except offset, which
+// is applied to every timestamp. A line with several timestamps is repeated at each of them. Lines with
+// a single timestamp must not go back in time, and word timestamps must rise within their line.
+func parseLRC(text string) (SyncedLyrics, error) {
+	synced := SyncedLyrics{Tags: map[string]string{}, Lines: []SyncedLine{}}
+	offset := 0
+	lastSingle, lastSingleLine := -1, 0
+
+	text = lineEndingRe.ReplaceAllString(strings.TrimPrefix(text, "\ufeff"), "\n")
+	for number, raw := range strings.Split(text, "\n") {
+		number++
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+
+		var times []int
+		for {
+			m := lrcTimeRe.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			ms, err := lrcMillis(m[1], m[2], m[3])
+			if err != nil {
+				return SyncedLyrics{}, fmt.Errorf("line %d: %v", number, err)
+			}
+			times = append(times, ms)
+			line = line[len(m[0]):]
+		}
+
+		if len(times) == 0 {
+			m := lrcTagRe.FindStringSubmatch(line)
+			if m == nil {
+				return SyncedLyrics{}, fmt.Errorf("line %d: expected a [mm:ss.xx] timestamp or an [id:value] tag", number)
+			}
+			name, value := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+			if name == "offset" {
+				parsed, err := strconv.Atoi(strings.TrimPrefix(value, "+"))
+				if err != nil {
+					return SyncedLyrics{}, fmt.Errorf("line %d: invalid offset %q", number, value)
+				}
+				offset = parsed
+				continue
+			}
+			synced.Tags[name] = value
+			continue
+		}
+
+		if len(times) == 1 {
+			if times[0] < lastSingle {
+				return SyncedLyrics{}, fmt.Errorf("line %d: timestamp %s is before %s on line %d",
+					number, formatLRCTime(times[0]), formatLRCTime(lastSingle), lastSingleLine)
+			}
+			lastSingle, lastSingleLine = times[0], number
+		}
+
+		words, wordsEnd, plain, err := parseLRCWords(line)
+		if err != nil {
+			return SyncedLyrics{}, fmt.Errorf("line %d: %v", number, err)
+		}
+		for _, at := range times {
+			if len(words) > 0 && words[0].TimeMs < at {
+				return SyncedLyrics{}, fmt.Errorf("line %d: word at %s starts before its line at %s",
+					number, formatLRCTime(words[0].TimeMs), formatLRCTime(at))
+			}
+			synced.Lines = append(synced.Lines, SyncedLine{TimeMs: at, Text: plain, Words: words, WordsEndMs: wordsEnd})
+		}
+	}
+
+	// A positive offset makes lyrics appear sooner.
+	for i := range synced.Lines {
+		line := &synced.Lines[i]
+		line.TimeMs = max(line.TimeMs-offset, 0)
+		if len(line.Words) > 0 {
+			words := make([]SyncedWord, len(line.Words))
+			for j, word := range line.Words {
+				words[j] = SyncedWord{TimeMs: max(word.TimeMs-offset, 0), Text: word.Text}
+			}
+			line.Words = words
+		}
+		if line.WordsEndMs != nil {
+			end := max(*line.WordsEndMs-offset, 0)
+			line.WordsEndMs = &end
+		}
+	}
+	sort.SliceStable(synced.Lines, func(i, j int) bool { return synced.Lines[i].TimeMs < synced.Lines[j].TimeMs })
+	for i := range synced.Lines {
+		synced.Lines[i].Index = i
+	}
+
+	if len(synced.Lines) == 0 {
+		return SyncedLyrics{}, errors.New("no timestamped lines")
+	}
+	return synced, nil
+}
+
+// parseLRCWords splits "<00:01.00>Hello <00:01.50>world <00:02.00>" into timed words, the time the
+// last word ends if a trailing timestamp gives it, and the plain text.
+func parseLRCWords(line string) ([]SyncedWord, *int, string, error) {
+	matches := lrcWordTimeRe.FindAllStringSubmatchIndex(line, -1)
+	if matches == nil {
+		return nil, nil, line, nil
+	}
+
+	words := make([]SyncedWord, 0, len(matches))
+	plain := strings.Builder{}
+	plain.WriteString(line[:matches[0][0]])
+	last := -1
+	for i, m := range matches {
+		ms, err := lrcMillis(line[m[2]:m[3]], line[m[4]:m[5]], substr(line, m[6], m[7]))
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if ms < last {
+			return nil, nil, "", fmt.Errorf("word timestamp %s is before %s", formatLRCTime(ms), formatLRCTime(last))
+		}
+		last = ms
+		end := len(line)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		word := line[m[1]:end]
+		plain.WriteString(word)
+		if strings.TrimSpace(word) == "" {
+			// A timestamp followed by nothing marks when the last word ends.
+			if i == len(matches)-1 {
+				return words, &ms, strings.TrimSpace(plain.String()), nil
+			}
+			continue
+		}
+		words = append(words, SyncedWord{TimeMs: ms, Text: word})
+	}
+	return words, nil, strings.TrimSpace(plain.String()), nil
+}
+
+func substr(s string, start, end int) string {
+	if start < 0 {
+		return ""
+	}
+	return s[start:end]
+}
+
+// formatLRC renders synced lyrics as LRC; word timestamps are included only when enhanced is set.
+func formatLRC(synced SyncedLyrics, enhanced bool) string {
+	var b strings.Builder
+	names := make([]string, 0, len(synced.Tags))
+	for name := range synced.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "[%s:%s]\n", name, synced.Tags[name])
+	}
+
+	for _, line := range synced.Lines {
+		fmt.Fprintf(&b, "[%s]", formatLRCTime(line.TimeMs))
+		if !enhanced || len(line.Words) == 0 {
+			b.WriteString(line.Text)
+		} else {
+			for _, word := range line.Words {
+				fmt.Fprintf(&b, "<%s>%s", formatLRCTime(word.TimeMs), word.Text)
+			}
+			if line.WordsEndMs != nil {
+				fmt.Fprintf(&b, "<%s>", formatLRCTime(*line.WordsEndMs))
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// fetchSyncedLyrics loads the synced lyrics of a song; sql.ErrNoRows means it has none.
+func fetchSyncedLyrics(idSong int) (SyncedLyrics, error) {
+	var row struct {
+		Tags  []byte `db:"tags"`
+		Lines []byte `db:"lines"`
+	}
+	err := db.Get(&row, "SELECT tags, lines FROM song_synced_lyrics WHERE id_song = $1", idSong)
+	if err != nil {
+		return SyncedLyrics{}, err
+	}
+
+	var synced SyncedLyrics
+	if err := json.Unmarshal(row.Tags, &synced.Tags); err != nil {
+		return SyncedLyrics{}, err
+	}
+	if err := json.Unmarshal(row.Lines, &synced.Lines); err != nil {
+		return SyncedLyrics{}, err
+	}
+	return synced, nil
+}
+
+// @Summary Upload time-synced lyrics
+// @Description Store LRC lyrics for a song, replacing any stored before. Enhanced LRC word timestamps (<mm:ss.xx>) are kept. Lines must not go back in time and word timestamps must rise within their line; the [offset] tag is applied to all timestamps.
+// @Tags lyrics
+// @Accept  plain
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param input body string true "LRC text"
+// @Success 200 {object} SyncedLyrics "The stored lyrics"
+// @Failure 400 {string} string "Invalid LRC"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to store synced lyrics"
+// @Router /songs/{id_song}/lrc [put]
+func putSyncedLyrics(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request putSyncedLyrics")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLRCSize+1))
+	if err != nil || len(body) > maxLRCSize {
+		slog.Warn("Invalid LRC body", "error", err, "size", len(body))
+		http.Error(w, fmt.Sprintf("LRC must be at most %d bytes", maxLRCSize), http.StatusBadRequest)
+		return
+	}
+
+	synced, err := parseLRC(string(body))
+	if err != nil {
+		slog.Warn("Invalid LRC", "id_song", idSong, "error", err)
+		http.Error(w, "Invalid LRC: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags, _ := json.Marshal(synced.Tags)
+	lines, _ := json.Marshal(synced.Lines)
+	_, err = db.Exec(`
+		INSERT INTO song_synced_lyrics (id_song, tags, lines) VALUES ($1, $2, $3)
+		ON CONFLICT (id_song) DO UPDATE SET tags = EXCLUDED.tags, lines = EXCLUDED.lines, updated_at = now()`,
+		idSong, tags, lines)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to store synced lyrics", "error", err)
+		http.Error(w, "Failed to store synced lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(synced)
+
+	slog.Debug("Synced lyrics stored successfully", "id_song", idSong, "lines", len(synced.Lines))
+}
+
+// @Summary Export time-synced lyrics
+// @Description Get the synced lyrics of a song as LRC, or as JSON with format=json.
+// @Tags lyrics
+// @Accept  json
+// @Produce  plain
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param enhanced query bool false "Include word timestamps (default true)"
+// @Param format query string false "lrc (default) or json"
+// @Success 200 {string} string "LRC text"
+// @Success 200 {object} SyncedLyrics "Synced lyrics (format=json)"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song has no synced lyrics"
+// @Failure 500 {string} string "Failed to fetch synced lyrics"
+// @Router /songs/{id_song}/lrc [get]
+func getSyncedLyrics(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getSyncedLyrics")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	enhanced := true
+	if value := r.URL.Query().Get("enhanced"); value != "" {
+		var err error
+		if enhanced, err = strconv.ParseBool(value); err != nil {
+			slog.Warn("Invalid enhanced parameter", "enhanced", value)
+			http.Error(w, "Invalid 'enhanced' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "lrc" && format != "json" {
+		slog.Warn("Invalid format parameter", "format", format)
+		http.Error(w, "Invalid 'format' parameter, expected lrc or json", http.StatusBadRequest)
+		return
+	}
+
+	synced, err := fetchSyncedLyrics(idSong)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song has no synced lyrics", "id_song", idSong)
+			http.Error(w, "Song has no synced lyrics", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch synced lyrics", "error", err)
+		http.Error(w, "Failed to fetch synced lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "json" {
+		if !enhanced {
+			for i := range synced.Lines {
+				synced.Lines[i].Words, synced.Lines[i].WordsEndMs = nil, nil
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(synced)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(formatLRC(synced, enhanced)))
+}
+
+// @Summary Delete time-synced lyrics
+// @Description Remove the synced lyrics of a song.
+// @Tags lyrics
+// @Param id_song path int true "ID of the song"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song has no synced lyrics"
+// @Failure 500 {string} string "Failed to delete synced lyrics"
+// @Router /songs/{id_song}/lrc [delete]
+func deleteSyncedLyrics(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request deleteSyncedLyrics")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM song_synced_lyrics WHERE id_song = $1", idSong)
+	if err != nil {
+		slog.Error("Failed to delete synced lyrics", "error", err)
+		http.Error(w, "Failed to delete synced lyrics", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		slog.Warn("Song has no synced lyrics", "id_song", idSong)
+		http.Error(w, "Song has no synced lyrics", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Karaoke position lookup
+// @Description Get the line, and with enhanced LRC the word, being sung at a playback position, with up to 'window' lines before and after it. Before the first line there is no active line and 'after' starts with the first one.
+// @Tags lyrics
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param position query int true "Playback position in milliseconds"
+// @Param window query int false "Lines to return before and after the active one (default 0, at most 50)"
+// @Success 200 {object} KaraokeFrame "Active line and its surroundings"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song has no synced lyrics"
+// @Failure 500 {string} string "Failed to fetch synced lyrics"
+// @Router /songs/{id_song}/karaoke [get]
+func getKaraokeFrame(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getKaraokeFrame")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	position, err := strconv.Atoi(r.URL.Query().Get("position"))
+	if err != nil || position < 0 {
+		slog.Warn("Invalid position parameter", "position", r.URL.Query().Get("position"))
+		http.Error(w, "Invalid 'position' parameter, expected milliseconds", http.StatusBadRequest)
+		return
+	}
+	window := 0
+	if value := r.URL.Query().Get("window"); value != "" {
+		window, err = strconv.Atoi(value)
+		if err != nil || window < 0 {
+			slog.Warn("Invalid window parameter", "window", value)
+			http.Error(w, "Invalid 'window' parameter", http.StatusBadRequest)
+			return
+		}
+		window = min(window, maxKaraokeWindow)
+	}
+
+	synced, err := fetchSyncedLyrics(idSong)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song has no synced lyrics", "id_song", idSong)
+			http.Error(w, "Song has no synced lyrics", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch synced lyrics", "error", err)
+		http.Error(w, "Failed to fetch synced lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(karaokeFrame(idSong, synced, position, window))
+}
+
+// karaokeFrame finds the line sung at position. Before the first line nothing is active and the
+// first line is still returned in After, so the player can show what comes next.
+func karaokeFrame(idSong int, synced SyncedLyrics, position, window int) KaraokeFrame {
+	lines := synced.Lines
+	for i := 0; i+1 < len(lines); i++ {
+		end := lines[i+1].TimeMs
+		lines[i].EndMs = &end
+	}
+
+	// The active line is the last one that has started.
+	active := sort.Search(len(lines), func(i int) bool { return lines[i].TimeMs > position }) - 1
+
+	frame := KaraokeFrame{SongID: idSong, PositionMs: position, Before: []SyncedLine{}}
+	after := window
+	if active >= 0 {
+		line := lines[active]
+		frame.Line = &line
+		for i, word := range line.Words {
+			if word.TimeMs <= position {
+				frame.WordIndex = &i
+			}
+		}
+		// Once the last word has ended none is being sung, though the line stays on screen.
+		if line.WordsEndMs != nil && position >= *line.WordsEndMs {
+			frame.WordIndex = nil
+		}
+		frame.Before = lines[max(active-window, 0):active]
+	} else {
+		after = max(window, 1)
+	}
+	frame.After = lines[active+1 : min(active+1+after, len(lines))]
+	return frame
+}
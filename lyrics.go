@@ -0,0 +1,307 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Section types recognized in lyrics markup.
+const (
+	sectionVerse      = "verse"
+	sectionPreChorus  = "pre_chorus"
+	sectionChorus     = "chorus"
+	sectionPostChorus = "post_chorus"
+	sectionBridge     = "bridge"
+	sectionIntro      = "intro"
+	sectionOutro      = "outro"
+	sectionHook       = "hook"
+	sectionRefrain    = "refrain"
+	sectionInterlude  = "interlude"
+	sectionSolo       = "solo"
+	// sectionOther is a bracketed header we don't know, like [Spoken Word].
+	sectionOther = "other"
+	// sectionUnlabeled is a block without a header.
+	sectionUnlabeled = "unlabeled"
+)
+
+// sectionKeywords maps the first words of a header to a section type, longest first where they overlap.
+var sectionKeywords = []struct {
+	prefix      string
+	sectionType string
+}{
+	{"pre-chorus", sectionPreChorus},
+	{"pre chorus", sectionPreChorus},
+	{"prechorus", sectionPreChorus},
+	{"post-chorus", sectionPostChorus},
+	{"post chorus", sectionPostChorus},
+	{"postchorus", sectionPostChorus},
+	{"verse", sectionVerse},
+	{"chorus", sectionChorus},
+	{"bridge", sectionBridge},
+	{"intro", sectionIntro},
+	{"outro", sectionOutro},
+	{"hook", sectionHook},
+	{"refrain", sectionRefrain},
+	{"interlude", sectionInterlude},
+	{"instrumental", sectionInterlude},
+	{"solo", sectionSolo},
+	{"куплет", sectionVerse},
+	{"предприпев", sectionPreChorus},
+	{"припев", sectionChorus},
+	{"бридж", sectionBridge},
+	{"вступление", sectionIntro},
+	{"интро", sectionIntro},
+	{"концовка", sectionOutro},
+	{"аутро", sectionOutro},
+	{"проигрыш", sectionInterlude},
+	{"соло", sectionSolo},
+}
+
+var (
+	// bracketHeaderRe matches "[Chorus]", "[Verse 2: Artist]" and the like.
+	bracketHeaderRe = regexp.MustCompile(`^\[([^\[\]]+)\]$`)
+	// plainHeaderRe matches "(Chorus)" and "Verse 2:"; these count only with a known keyword.
+	plainHeaderRe = regexp.MustCompile(`^(?:\(([^()]+)\)|([^:]{1,40}):)$`)
+	// repeatRe matches repetition marks such as "x2" or "×3".
+	repeatRe = regexp.MustCompile(`(?i)\s*[x×]\s*\d+\s*$`)
+	// sectionNumberRe finds the number of a section, as in "Verse 2".
+	sectionNumberRe = regexp.MustCompile(`^\D*?(\d+)`)
+	// plainHeaderRestRe is what may follow the keyword of an unbracketed header: at most a number.
+	plainHeaderRestRe = regexp.MustCompile(`^\s*\d*$`)
+)
+
+// parseSectionHeader reports whether line is a section header and what it declares.
+func parseSectionHeader(line string) (sectionType, label string, number int, ok bool) {
+	line = strings.TrimSpace(line)
+
+	explicit := false
+	if m := bracketHeaderRe.FindStringSubmatch(line); m != nil {
+		label, explicit = strings.TrimSpace(m[1]), true
+	} else if m := plainHeaderRe.FindStringSubmatch(line); m != nil {
+		label = strings.TrimSpace(m[1] + m[2])
+	} else {
+		return "", "", 0, false
+	}
+
+	// "Chorus: Artist" names the performer; "Chorus x2" repeats. Neither changes the type.
+	name, _, _ := strings.Cut(label, ":")
+	name = strings.ToLower(strings.TrimSpace(repeatRe.ReplaceAllString(name, "")))
+	for _, keyword := range sectionKeywords {
+		if strings.HasPrefix(name, keyword.prefix) {
+			rest := name[len(keyword.prefix):]
+			// The keyword has to be a word of its own, and without brackets the whole header, so
+			// "Introduce yourself:" and "(hook, line and sinker)" stay lyrics.
+			if r, _ := utf8.DecodeRuneInString(rest); unicode.IsLetter(r) {
+				continue
+			}
+			if !explicit && !plainHeaderRestRe.MatchString(rest) {
+				continue
+			}
+			if m := sectionNumberRe.FindStringSubmatch(rest); m != nil {
+				number, _ = strconv.Atoi(m[1])
+			}
+			return keyword.sectionType, label, number, true
+		}
+	}
+	if explicit {
+		return sectionOther, label, 0, true
+	}
+	return "", "", 0, false
+}
+
+// parseLyricSections splits lyrics into ordered sections. A header starts a section, and a blank line
+// ends it, so text without markup comes out as its blank-line separated blocks. A header with no lines
+// of its own, like a lone "[Chorus]", repeats the last section with the same type and number.
+func parseLyricSections(text string) []LyricSection {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+
+	var sections []LyricSection
+	var current *LyricSection
+	closeSection := func() {
+		if current == nil {
+			return
+		}
+		if len(current.Lines) == 0 {
+			for i := len(sections) - 1; i >= 0; i-- {
+				if sections[i].Type == current.Type && sections[i].Number == current.Number && len(sections[i].Lines) > 0 {
+					current.Lines = append([]string(nil), sections[i].Lines...)
+					current.Repeat = true
+					break
+				}
+			}
+		}
+		if len(current.Lines) > 0 || current.Label != "" {
+			if current.Lines == nil {
+				current.Lines = []string{}
+			}
+			current.Index = len(sections)
+			sections = append(sections, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if sectionType, label, number, ok := parseSectionHeader(line); ok {
+			closeSection()
+			current = &LyricSection{Type: sectionType, Label: label, Number: number}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			// Blank lines right after a header belong to it.
+			if current != nil && len(current.Lines) > 0 {
+				closeSection()
+			}
+			continue
+		}
+		if current == nil {
+			current = &LyricSection{Type: sectionUnlabeled}
+		}
+		current.Lines = append(current.Lines, line)
+	}
+	closeSection()
+	return sections
+}
+
+// syncLyricSections rewrites the stored sections of a song from its current lyrics.
+func syncLyricSections(tx *sqlx.Tx, idSong int) error {
+	var lyrics string
+	if err := tx.Get(&lyrics, "SELECT COALESCE(lyrics, '') FROM songs WHERE id_song = $1", idSong); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM song_sections WHERE id_song = $1", idSong); err != nil {
+		return err
+	}
+	for _, section := range parseLyricSections(lyrics) {
+		_, err := tx.Exec(`
+			INSERT INTO song_sections (id_song, position, section_type, label, number, lines, repeat)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			idSong, section.Index, section.Type, section.Label, section.Number, pq.Array(section.Lines), section.Repeat)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lyricSections returns the stored sections of a song, parsing the lyrics when none are stored yet.
+func lyricSections(idSong int, lyrics string) ([]LyricSection, error) {
+	var rows []struct {
+		LyricSection
+		Lines pq.StringArray `db:"lines"`
+	}
+	query := `
+		SELECT position, section_type, label, number, lines, repeat
+		FROM song_sections
+		WHERE id_song = $1
+		ORDER BY position`
+	if err := db.Select(&rows, query, idSong); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return parseLyricSections(lyrics), nil
+	}
+
+	sections := make([]LyricSection, 0, len(rows))
+	for _, row := range rows {
+		section := row.LyricSection
+		section.Lines = row.Lines
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+// Units getSongText can paginate by.
+const (
+	unitVerse = "verse"
+	unitLine  = "line"
+	unitChar  = "char"
+)
+
+// defaultPageLimits are the page sizes used when a request gives no limit. In JSON mode verses
+// default to all of them, as sections did before pagination units existed.
+var defaultPageLimits = map[string]int{unitVerse: 1, unitLine: 10, unitChar: 1000}
+
+var (
+	lineEndingRe    = regexp.MustCompile(`\r\n?`)
+	trailingSpaceRe = regexp.MustCompile(`[ \t]+\n`)
+	blankLinesRe    = regexp.MustCompile(`\n{3,}`)
+)
+
+// normalizeLyrics unifies line endings, drops trailing whitespace and collapses runs of blank lines,
+// so verses are always separated by exactly one empty line.
+func normalizeLyrics(text string) string {
+	text = lineEndingRe.ReplaceAllString(text, "\n")
+	text = trailingSpaceRe.ReplaceAllString(text+"\n", "\n")
+	text = blankLinesRe.ReplaceAllString(text, "\n\n")
+	return strings.Trim(text, "\n")
+}
+
+// splitVerses returns the blank-line separated blocks of lyrics.
+func splitVerses(text string) []string {
+	text = normalizeLyrics(text)
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n\n")
+}
+
+// splitLines returns the non-empty lines of lyrics.
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(normalizeLyrics(text), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// pageBounds returns the slice bounds of a page and the number of pages; past the end the page is empty.
+func pageBounds(total, page, limit int) (int, int, int) {
+	totalPages := (total + limit - 1) / limit
+	start := total
+	if page-1 < totalPages {
+		start = (page - 1) * limit
+	}
+	return start, min(start+limit, total), totalPages
+}
+
+// backfillLyricSections parses lyrics of songs stored before sections existed.
+func backfillLyricSections() {
+	var ids []int
+	err := db.Select(&ids, `
+		SELECT s.id_song FROM songs s
+		WHERE COALESCE(s.lyrics, '') <> ''
+			AND NOT EXISTS (SELECT 1 FROM song_sections ss WHERE ss.id_song = s.id_song)`)
+	if err != nil {
+		slog.Error("Failed to find songs without lyric sections", "error", err)
+		return
+	}
+
+	for _, idSong := range ids {
+		tx, err := db.Beginx()
+		if err != nil {
+			slog.Error("Failed to begin transaction", "error", err)
+			return
+		}
+		if err := syncLyricSections(tx, idSong); err != nil {
+			tx.Rollback()
+			slog.Error("Failed to parse lyric sections", "id_song", idSong, "error", err)
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			slog.Error("Failed to commit lyric sections", "id_song", idSong, "error", err)
+		}
+	}
+	if len(ids) > 0 {
+		slog.Info("Lyric sections backfilled", "songs", len(ids))
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
@@ -28,6 +29,14 @@ func main() {
 	)
 	slog.SetDefault(logger)
 
+	if len(os.Args) > 1 && os.Args[1] == "mock-api" {
+		if err := runMockAPI(os.Args[2:]); err != nil {
+			slog.Error("Mock external API stopped", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	slog.Info("Loading.env")
 	if err := godotenv.Load(); err != nil {
 		slog.Error("Error loading .env file")
@@ -39,6 +48,17 @@ func main() {
 		slog.Error("Database connection is nil after initialization")
 	}
 
+	enricher = newProviderChainFromEnv()
+	loadEnrichmentPolicy()
+	queue = newEnrichmentQueueFromEnv()
+	refresher = newSongRefresherFromEnv()
+	if db != nil {
+		queue.Start(context.Background())
+		refresher.Start(context.Background())
+		go backfillLyricSections()
+		go backfillLyricsLanguages()
+	}
+
 	slog.Info("Setting up routes")
 	r := setupRoutes()
 	slog.Info("Routes set up successfully!")
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS song_relations (
+    id_relation     SERIAL PRIMARY KEY,
+    id_song         INT NOT NULL,
+    id_original     INT NOT NULL,
+    relation_type   VARCHAR(32) NOT NULL,
+    CONSTRAINT fk_relation_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE,
+    CONSTRAINT fk_relation_original FOREIGN KEY (id_original) REFERENCES songs (id_song) ON DELETE CASCADE,
+    CONSTRAINT chk_relation_type CHECK (relation_type IN ('cover', 'remix', 'live', 'translation')),
+    CONSTRAINT chk_relation_self CHECK (id_song <> id_original),
+    CONSTRAINT uq_song_relation UNIQUE (id_song, id_original, relation_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_song_relations_original ON song_relations (id_original);
@@ -0,0 +1,24 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS isrc VARCHAR(12);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS iswc VARCHAR(11);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_songs_isrc ON songs (isrc);
+-- Covers and translations share the ISWC of the underlying work, so it is indexed but not unique.
+CREATE INDEX IF NOT EXISTS idx_songs_iswc ON songs (iswc);
+
+CREATE TABLE IF NOT EXISTS song_external_ids (
+    id_song         INT NOT NULL,
+    provider        VARCHAR(32) NOT NULL,
+    external_id     VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id_song, provider),
+    CONSTRAINT fk_external_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE,
+    CONSTRAINT uq_song_external_id UNIQUE (provider, external_id)
+);
+
+CREATE TABLE IF NOT EXISTS group_external_ids (
+    id_group        INT NOT NULL,
+    provider        VARCHAR(32) NOT NULL,
+    external_id     VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id_group, provider),
+    CONSTRAINT fk_external_group FOREIGN KEY (id_group) REFERENCES musicGroups (id_group) ON DELETE CASCADE,
+    CONSTRAINT uq_group_external_id UNIQUE (provider, external_id)
+);
@@ -0,0 +1,14 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS release_date_precision VARCHAR(5);
+
+UPDATE songs SET release_date_precision = 'day' WHERE release_date IS NOT NULL;
+
+-- addSong used to store this date whenever the external API was unavailable.
+UPDATE songs SET release_date = NULL, release_date_precision = NULL
+WHERE release_date = '2024-11-25' AND link = 'Link Placeholder';
+
+ALTER TABLE songs ADD CONSTRAINT chk_release_date_precision
+    CHECK (release_date_precision IN ('year', 'month', 'day'));
+ALTER TABLE songs ADD CONSTRAINT chk_release_date_with_precision
+    CHECK ((release_date IS NULL) = (release_date_precision IS NULL));
+
+CREATE INDEX IF NOT EXISTS idx_songs_release_date ON songs (release_date);
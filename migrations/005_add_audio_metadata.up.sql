@@ -0,0 +1,11 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS duration INT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS bpm NUMERIC(5, 2);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS musical_key VARCHAR(3);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS time_signature VARCHAR(5);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS explicit BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE songs ADD CONSTRAINT chk_duration CHECK (duration > 0);
+ALTER TABLE songs ADD CONSTRAINT chk_bpm CHECK (bpm > 0);
+
+CREATE INDEX IF NOT EXISTS idx_songs_bpm ON songs (bpm);
+CREATE INDEX IF NOT EXISTS idx_songs_duration ON songs (duration);
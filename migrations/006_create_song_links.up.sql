@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS song_links (
+    id_link         SERIAL PRIMARY KEY,
+    id_song         INT NOT NULL,
+    url             VARCHAR(2048) NOT NULL,
+    provider        VARCHAR(16) NOT NULL,
+    CONSTRAINT fk_link_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE,
+    CONSTRAINT chk_link_provider CHECK (provider IN ('youtube', 'spotify', 'bandcamp', 'soundcloud', 'generic')),
+    CONSTRAINT uq_song_link UNIQUE (id_song, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_song_links_provider ON song_links (provider, id_song);
+
+-- Only real URLs are carried over; "Link Placeholder" and other junk is dropped.
+INSERT INTO song_links (id_song, url, provider)
+SELECT id_song, link,
+    CASE
+        WHEN link ~* '^https?://([^/]+\.)?(youtube\.com|youtu\.be)(:[0-9]+)?(/|\?|#|$)' THEN 'youtube'
+        WHEN link ~* '^https?://([^/]+\.)?spotify\.com(:[0-9]+)?(/|\?|#|$)' THEN 'spotify'
+        WHEN link ~* '^https?://([^/]+\.)?bandcamp\.com(:[0-9]+)?(/|\?|#|$)' THEN 'bandcamp'
+        WHEN link ~* '^https?://([^/]+\.)?soundcloud\.com(:[0-9]+)?(/|\?|#|$)' THEN 'soundcloud'
+        ELSE 'generic'
+    END
+FROM songs
+WHERE link ~* '^https?://[^/?#\s]+';
+
+ALTER TABLE songs DROP COLUMN IF EXISTS link;
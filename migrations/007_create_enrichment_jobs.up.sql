@@ -0,0 +1,17 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS enrichment_status VARCHAR(16) NOT NULL DEFAULT 'done';
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS enrichment_error TEXT;
+ALTER TABLE songs ADD CONSTRAINT chk_enrichment_status
+    CHECK (enrichment_status IN ('pending', 'done', 'failed', 'not_found'));
+
+CREATE TABLE IF NOT EXISTS enrichment_jobs (
+    id_job          SERIAL PRIMARY KEY,
+    id_song         INT NOT NULL UNIQUE,
+    attempts        INT NOT NULL DEFAULT 0,
+    run_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_until    TIMESTAMPTZ,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_job_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_run_at ON enrichment_jobs (run_at);
@@ -0,0 +1 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS detail_sources JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,12 @@
+-- Songs stored with placeholder details before enrichment policies existed are fetched again.
+UPDATE songs SET lyrics = '', enrichment_status = 'pending'
+WHERE lyrics LIKE 'Text Placeholder%';
+
+INSERT INTO enrichment_jobs (id_song)
+SELECT id_song FROM songs WHERE enrichment_status = 'pending'
+ON CONFLICT (id_song) DO NOTHING;
+
+ALTER TABLE songs ADD CONSTRAINT chk_lyrics_not_placeholder
+    CHECK (lyrics NOT LIKE 'Text Placeholder%');
+ALTER TABLE song_links ADD CONSTRAINT chk_link_not_placeholder
+    CHECK (url NOT ILIKE '%Link Placeholder%');
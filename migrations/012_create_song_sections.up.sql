@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS song_sections (
+    id_section      SERIAL PRIMARY KEY,
+    id_song         INT NOT NULL,
+    position        INT NOT NULL,
+    section_type    VARCHAR(16) NOT NULL,
+    label           TEXT NOT NULL DEFAULT '',
+    number          INT NOT NULL DEFAULT 0,
+    lines           TEXT[] NOT NULL DEFAULT '{}',
+    repeat          BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT fk_section_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE,
+    CONSTRAINT uq_section_position UNIQUE (id_song, position)
+);
@@ -0,0 +1,16 @@
+-- Unify line endings, drop trailing whitespace and collapse blank-line runs, as the service now does on write.
+WITH normalized AS (
+    SELECT id_song,
+        btrim(regexp_replace(regexp_replace(regexp_replace(
+            lyrics, E'\r\n?', E'\n', 'g'), E'[ \t]+(\n|$)', E'\\1', 'g'), E'\n{3,}', E'\n\n', 'g'), E'\n') AS lyrics
+    FROM songs
+    WHERE lyrics IS NOT NULL
+), changed AS (
+    UPDATE songs s
+    SET lyrics = n.lyrics
+    FROM normalized n
+    WHERE s.id_song = n.id_song AND s.lyrics IS DISTINCT FROM n.lyrics
+    RETURNING s.id_song
+)
+-- Sections of changed songs are parsed again at startup.
+DELETE FROM song_sections WHERE id_song IN (SELECT id_song FROM changed);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS song_synced_lyrics (
+    id_song         INT PRIMARY KEY,
+    tags            JSONB NOT NULL DEFAULT '{}',
+    lines           JSONB NOT NULL DEFAULT '[]',
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_synced_lyrics_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE
+);
@@ -0,0 +1,12 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS language VARCHAR(35);
+
+CREATE TABLE IF NOT EXISTS song_translations (
+    id_translation  SERIAL PRIMARY KEY,
+    id_song         INT NOT NULL,
+    language        VARCHAR(35) NOT NULL,
+    lyrics          TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_translation_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE,
+    CONSTRAINT uq_translation_language UNIQUE (id_song, language)
+);
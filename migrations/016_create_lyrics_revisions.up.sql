@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS lyrics_revisions (
+    id_revision     SERIAL PRIMARY KEY,
+    id_song         INT NOT NULL,
+    revision        INT NOT NULL,
+    lyrics          TEXT NOT NULL,
+    author          VARCHAR(255) NOT NULL,
+    source          VARCHAR(16) NOT NULL,
+    restored_from   INT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_revision_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE,
+    CONSTRAINT uq_revision_number UNIQUE (id_song, revision)
+);
+
+-- Lyrics stored before history existed become the first revision of their song.
+INSERT INTO lyrics_revisions (id_song, revision, lyrics, author, source)
+SELECT id_song, 1, lyrics, 'system', 'import'
+FROM songs
+WHERE COALESCE(lyrics, '') <> ''
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS annotations (
+    id_annotation   SERIAL PRIMARY KEY,
+    id_song         INT NOT NULL,
+    start_line      INT NOT NULL,
+    end_line        INT NOT NULL,
+    start_char      INT,
+    end_char        INT,
+    quote           TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    author          VARCHAR(255) NOT NULL,
+    orphaned        BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_annotation_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE,
+    CONSTRAINT chk_annotation_lines CHECK (start_line >= 1 AND end_line >= start_line),
+    CONSTRAINT chk_annotation_chars CHECK ((start_char IS NULL) = (end_char IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_song ON annotations (id_song, start_line);
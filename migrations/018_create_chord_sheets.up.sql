@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS chord_sheets (
+    id_song         INT PRIMARY KEY,
+    source          TEXT NOT NULL,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_chord_sheet_song FOREIGN KEY (id_song) REFERENCES songs (id_song) ON DELETE CASCADE
+);
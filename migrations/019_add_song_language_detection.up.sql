@@ -0,0 +1,7 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS language_confidence REAL;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS language_source VARCHAR(16);
+
+-- Languages set before detection existed were set by hand.
+UPDATE songs SET language_source = 'manual' WHERE language IS NOT NULL AND language_source IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_songs_language ON songs (language);
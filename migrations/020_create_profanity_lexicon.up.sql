@@ -0,0 +1,21 @@
+-- Who set songs.explicit: manual (PUT /songs) and provider values stand, lexicon flags follow the lyrics.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS explicit_source VARCHAR(16);
+
+-- explicit is never NULL, so a false flag may just be the column default; only a true one came from a provider.
+UPDATE songs SET explicit_source = 'provider' WHERE explicit AND explicit_source IS NULL;
+
+CREATE TABLE IF NOT EXISTS profanity_terms (
+    id_term     SERIAL PRIMARY KEY,
+    language    VARCHAR(35) NOT NULL,
+    term        VARCHAR(100) NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT uq_profanity_term UNIQUE (language, term)
+);
+
+-- A starting lexicon; * matches any letters at the start or end of a word.
+INSERT INTO profanity_terms (language, term) VALUES
+    ('en', 'fuck*'), ('en', 'motherfuck*'), ('en', 'shit'), ('en', 'shits'), ('en', 'shitty'), ('en', 'bullshit'), ('en', 'bitch*'),
+    ('en', 'cunt*'), ('en', 'asshole*'), ('en', 'dickhead*'), ('en', 'goddamn*'),
+    ('ru', '*хуй*'), ('ru', '*хуе*'), ('ru', '*хуя*'), ('ru', '*пизд*'), ('ru', 'бля'), ('ru', 'блять'),
+    ('ru', 'бляд*'), ('ru', 'ебат*'), ('ru', 'ебал*'), ('ru', 'заеб*'), ('ru', 'уеб*'), ('ru', 'сука'), ('ru', 'суки')
+ON CONFLICT DO NOTHING;
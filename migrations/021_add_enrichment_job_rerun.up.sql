@@ -0,0 +1,2 @@
+-- Set when a song is enqueued again while a worker holds its job, so the job runs once more afterwards.
+ALTER TABLE enrichment_jobs ADD COLUMN IF NOT EXISTS rerun BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+-- When enrichment of a song last ended, successfully or not; stale refreshes skip recent attempts.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS enrichment_attempted_at TIMESTAMPTZ;
+
+UPDATE songs SET enrichment_attempted_at = enriched_at WHERE enrichment_attempted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_songs_enrichment_attempted_at ON songs (enrichment_attempted_at);
@@ -0,0 +1,2 @@
+-- Refreshes must reach the providers, so their jobs skip the lookup cache.
+ALTER TABLE enrichment_jobs ADD COLUMN IF NOT EXISTS bypass_cache BOOLEAN NOT NULL DEFAULT false;
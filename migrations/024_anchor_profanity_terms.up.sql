@@ -0,0 +1,9 @@
+-- The infix seeds *хуй*, *хуе* and *хуя* also matched страхует, застрахуем and страхуя, so they are
+-- anchored to the start of the word, with the usual prefixed forms listed on their own. *пизд* stays
+-- an infix: no other word contains it. Songs they flagged are cleared by POST /profanity/rescan.
+DELETE FROM profanity_terms WHERE language = 'ru' AND term IN ('*хуй*', '*хуе*', '*хуя*');
+
+INSERT INTO profanity_terms (language, term) VALUES
+    ('ru', 'хуй*'), ('ru', 'хуе*'), ('ru', 'хуя*'), ('ru', 'хуи*'), ('ru', 'нахуй*'), ('ru', 'нахуя*'),
+    ('ru', 'нихуя*'), ('ru', 'похуй*'), ('ru', 'похуи*'), ('ru', 'охуе*'), ('ru', 'охуи*'), ('ru', 'захуя*')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Faults the mock API can inject instead of answering normally.
+const (
+	faultNotFound  = "not_found"
+	faultError     = "error"
+	faultMalformed = "malformed"
+	// faultRateLimited answers 429 with a one-second Retry-After.
+	faultRateLimited = "rate_limited"
+)
+
+// mockFaults controls how the mock API misbehaves. Rates are probabilities from 0 to 1.
+type mockFaults struct {
+	Latency       string  `json:"latency,omitempty"`
+	NotFoundRate  float64 `json:"not_found_rate"`
+	ErrorRate     float64 `json:"error_rate"`
+	MalformedRate float64 `json:"malformed_rate"`
+	RateLimitRate float64 `json:"rate_limited_rate"`
+}
+
+// mockFixture is one song the mock API knows. Every field other than group, song, fault and latency
+// is returned as is, so fixtures can mimic any upstream shape, e.g. {"releaseDate": "16.07.2006"}.
+type mockFixture struct {
+	fields  map[string]json.RawMessage
+	fault   string
+	latency time.Duration
+}
+
+// mockAPI serves the external music-info contract, GET /info?group=...&song=..., from fixtures.
+type mockAPI struct {
+	fixtures map[string]mockFixture
+
+	mu      sync.Mutex
+	faults  mockFaults
+	latency time.Duration
+}
+
+// runMockAPI is the "mock-api" subcommand. It blocks until the server stops.
+func runMockAPI(args []string) error {
+	flags := flag.NewFlagSet("mock-api", flag.ContinueOnError)
+	addr := flags.String("addr", ":8081", "address to listen on")
+	fixturesPath := flags.String("fixtures", "fixtures/mock_api.json", "JSON array of songs to serve")
+	var faults mockFaults
+	flags.StringVar(&faults.Latency, "latency", "", "delay before every answer, e.g. 500ms")
+	flags.Float64Var(&faults.NotFoundRate, "not-found-rate", 0, "share of requests answered with 404")
+	flags.Float64Var(&faults.ErrorRate, "error-rate", 0, "share of requests answered with 500")
+	flags.Float64Var(&faults.MalformedRate, "malformed-rate", 0, "share of requests answered with malformed JSON")
+	flags.Float64Var(&faults.RateLimitRate, "rate-limited-rate", 0, "share of requests answered with 429 and Retry-After")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	api, err := newMockAPI(*fixturesPath)
+	if err != nil {
+		return err
+	}
+	if err := api.setFaults(faults); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/info", api.info)
+	mux.HandleFunc("/faults", api.handleFaults)
+
+	slog.Info("Starting mock external API", "address", *addr, "fixtures", *fixturesPath, "songs", len(api.fixtures))
+	return http.ListenAndServe(*addr, mux)
+}
+
+func newMockAPI(path string) (*mockAPI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	var entries []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures: %w", err)
+	}
+
+	api := &mockAPI{fixtures: make(map[string]mockFixture, len(entries))}
+	for i, entry := range entries {
+		var group, song, fault, latency string
+		for name, target := range map[string]*string{"group": &group, "song": &song, "fault": &fault, "latency": &latency} {
+			if raw, ok := entry[name]; ok {
+				if err := json.Unmarshal(raw, target); err != nil {
+					return nil, fmt.Errorf("fixture %d: invalid %q: %w", i, name, err)
+				}
+				delete(entry, name)
+			}
+		}
+		if group == "" || song == "" {
+			return nil, fmt.Errorf("fixture %d: group and song are required", i)
+		}
+
+		fixture := mockFixture{fields: entry, fault: fault}
+		switch fault {
+		case "", faultNotFound, faultError, faultMalformed, faultRateLimited:
+		default:
+			return nil, fmt.Errorf("fixture %d: unknown fault %q", i, fault)
+		}
+		if latency != "" {
+			if fixture.latency, err = time.ParseDuration(latency); err != nil {
+				return nil, fmt.Errorf("fixture %d: invalid latency: %w", i, err)
+			}
+		}
+		api.fixtures[normalizeSongKey(group, song)] = fixture
+	}
+	return api, nil
+}
+
+func (a *mockAPI) setFaults(faults mockFaults) error {
+	var latency time.Duration
+	if faults.Latency != "" {
+		var err error
+		if latency, err = time.ParseDuration(faults.Latency); err != nil || latency < 0 {
+			return fmt.Errorf("invalid latency %q", faults.Latency)
+		}
+	}
+	for _, rate := range []float64{faults.NotFoundRate, faults.ErrorRate, faults.MalformedRate, faults.RateLimitRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("fault rates must be between 0 and 1")
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.faults, a.latency = faults, latency
+	return nil
+}
+
+// pickFault decides how to answer: a fixture's own fault wins over the random ones.
+func (a *mockAPI) pickFault(fixture mockFixture) (string, time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	latency := a.latency + fixture.latency
+	if fixture.fault != "" {
+		return fixture.fault, latency
+	}
+
+	roll := rand.Float64()
+	for _, f := range []struct {
+		fault string
+		rate  float64
+	}{
+		{faultNotFound, a.faults.NotFoundRate},
+		{faultError, a.faults.ErrorRate},
+		{faultMalformed, a.faults.MalformedRate},
+		{faultRateLimited, a.faults.RateLimitRate},
+	} {
+		if roll < f.rate {
+			return f.fault, latency
+		}
+		roll -= f.rate
+	}
+	return "", latency
+}
+
+func (a *mockAPI) info(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	group, song := r.URL.Query().Get("group"), r.URL.Query().Get("song")
+	if group == "" || song == "" {
+		http.Error(w, "Missing 'group' or 'song' parameter", http.StatusBadRequest)
+		return
+	}
+
+	fixture, known := a.fixtures[normalizeSongKey(group, song)]
+	fault, latency := a.pickFault(fixture)
+	if !known && fault == "" {
+		fault = faultNotFound
+	}
+	slog.Debug("Mock API request", "group", group, "song", song, "fault", fault, "latency", latency)
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch fault {
+	case faultNotFound:
+		http.Error(w, "Song not found", http.StatusNotFound)
+	case faultError:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	case faultRateLimited:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	case faultMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"releaseDate": "16.07.2006", "text": `)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fixture.fields)
+	}
+}
+
+// handleFaults shows the current fault settings on GET and replaces them on PUT,
+// so tests can switch failure modes without restarting the mock.
+func (a *mockAPI) handleFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var faults mockFaults
+		if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		if err := a.setFaults(faults); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("Mock API faults changed", "faults", faults)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	a.mu.Lock()
+	faults := a.faults
+	a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(faults)
+}
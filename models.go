@@ -1,18 +1,34 @@
 package main
 
+import "time"
+
 type Group struct {
-	ID        int    `db:"id_group" json:"id_group"`
-	GroupName string `db:"groupName" json:"group"`
+	ID          int               `db:"id_group" json:"id_group"`
+	GroupName   string            `db:"groupName" json:"group"`
+	ExternalIDs map[string]string `db:"-" json:"external_ids,omitempty"`
 }
 
 type Song struct {
-	ID          int    `db:"id_song" json:"id_song"`
-	GroupID     int    `db:"id_group" json:"id_group"`
-	GroupName   string `db:"group" json:"group"`
-	SongName    string `db:"song" json:"song"`
-	ReleaseDate string `db:"release_date" json:"release_date,omitempty"`
-	Lyrics      string `db:"lyrics" json:"text,omitempty"`
-	Link        string `db:"link" json:"link,omitempty"`
+	ID          int               `db:"id_song" json:"id_song"`
+	GroupID     int               `db:"id_group" json:"id_group"`
+	GroupName   string            `db:"group" json:"group"`
+	SongName    string            `db:"song" json:"song"`
+	ReleaseDate string            `db:"release_date" json:"release_date,omitempty"`
+	Precision   string            `db:"release_date_precision" json:"release_date_precision,omitempty"`
+	Lyrics      string            `db:"lyrics" json:"text,omitempty"`
+	Link        string            `db:"link" json:"link,omitempty"`
+	ISRC        *string           `db:"isrc" json:"isrc,omitempty"`
+	ISWC        *string           `db:"iswc" json:"iswc,omitempty"`
+	ExternalIDs map[string]string `db:"-" json:"external_ids,omitempty"`
+	Links       []SongLink        `db:"-" json:"links,omitempty"`
+	Enrichment  string            `db:"enrichment_status" json:"enrichment_status,omitempty"`
+	Sources     fieldSources      `db:"detail_sources" json:"sources,omitempty"`
+	// Language is the language tag of the lyrics, e.g. en; translations are stored separately.
+	// Unless set by hand it is detected from the lyrics, with the confidence of the detection.
+	Language           *string  `db:"language" json:"language,omitempty"`
+	LanguageConfidence *float64 `db:"language_confidence" json:"language_confidence,omitempty"`
+	LanguageSource     *string  `db:"language_source" json:"language_source,omitempty"`
+	AudioMetadata
 }
 
 type SongShort struct {
@@ -21,7 +37,305 @@ type SongShort struct {
 }
 
 type SongDetail struct {
-	ReleaseDate string `db:"release_date" json:"release_date,omitempty"`
-	Lyrics      string `db:"lyrics" json:"text,omitempty"`
-	Link        string `db:"link" json:"link,omitempty"`
+	ReleaseDate string       `db:"release_date" json:"release_date,omitempty"`
+	Precision   string       `db:"release_date_precision" json:"release_date_precision,omitempty"`
+	Lyrics      string       `db:"lyrics" json:"text,omitempty"`
+	Link        string       `db:"link" json:"link,omitempty"`
+	Sources     fieldSources `db:"detail_sources" json:"sources,omitempty"`
+	// Problems lists fetched values that were dropped because they didn't validate.
+	Problems []string `db:"-" json:"-"`
+	AudioMetadata
+}
+
+type AudioMetadata struct {
+	Duration      *int     `db:"duration" json:"duration,omitempty"`
+	BPM           *float64 `db:"bpm" json:"bpm,omitempty"`
+	Key           *string  `db:"musical_key" json:"key,omitempty"`
+	TimeSignature *string  `db:"time_signature" json:"time_signature,omitempty"`
+	Explicit      *bool    `db:"explicit" json:"explicit,omitempty"`
+}
+
+type SongRelation struct {
+	ID           int    `db:"id_relation" json:"id_relation"`
+	SongID       int    `db:"id_song" json:"id_song"`
+	OriginalID   int    `db:"id_original" json:"id_original"`
+	RelationType string `db:"relation_type" json:"type"`
+}
+
+type SongVersion struct {
+	ID           int           `db:"id_song" json:"id_song"`
+	GroupName    string        `db:"group" json:"group"`
+	SongName     string        `db:"song" json:"song"`
+	RelationType string        `db:"relation_type" json:"type,omitempty"`
+	Versions     []SongVersion `db:"-" json:"versions,omitempty"`
+}
+
+type ExternalID struct {
+	Provider   string `db:"provider" json:"provider"`
+	ExternalID string `db:"external_id" json:"id"`
+}
+
+type SongLink struct {
+	ID       int    `db:"id_link" json:"id_link"`
+	SongID   int    `db:"id_song" json:"id_song"`
+	URL      string `db:"url" json:"url"`
+	Provider string `db:"provider" json:"provider"`
+}
+
+type EnrichmentStatus struct {
+	SongID      int        `db:"id_song" json:"id_song"`
+	Status      string     `db:"enrichment_status" json:"status"`
+	Error       string     `db:"error" json:"error,omitempty"`
+	Attempts    int        `db:"attempts" json:"attempts"`
+	NextAttempt *time.Time `db:"next_attempt" json:"next_attempt,omitempty"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+type RefreshResult struct {
+	SongID    int           `db:"id_song" json:"id_song"`
+	GroupName string        `db:"group" json:"group"`
+	SongName  string        `db:"song" json:"song"`
+	Status    string        `db:"-" json:"status"`
+	Error     string        `db:"-" json:"error,omitempty"`
+	Changes   []FieldChange `db:"-" json:"changes,omitempty"`
+}
+
+type RefreshReport struct {
+	DryRun bool            `json:"dry_run"`
+	Songs  []RefreshResult `json:"songs"`
+}
+
+type CacheStats struct {
+	Enabled      bool    `json:"enabled"`
+	Entries      int     `json:"entries"`
+	Hits         int64   `json:"hits"`
+	NegativeHits int64   `json:"negative_hits"`
+	Misses       int64   `json:"misses"`
+	Bypasses     int64   `json:"bypasses"`
+	Evictions    int64   `json:"evictions"`
+	HitRatio     float64 `json:"hit_ratio"`
+}
+
+type LimiterStats struct {
+	Provider    string     `json:"provider"`
+	RateLimit   float64    `json:"rate_limit"`
+	Burst       int        `json:"burst"`
+	MaxInFlight int        `json:"max_in_flight"`
+	Queued      int64      `json:"queued"`
+	InFlight    int64      `json:"in_flight"`
+	Requests    int64      `json:"requests"`
+	Throttled   int64      `json:"throttled"`
+	AvgWaitMs   int64      `json:"avg_wait_ms"`
+	MaxWaitMs   int64      `json:"max_wait_ms"`
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+}
+
+type SongPreview struct {
+	Song          Song     `json:"song"`
+	GroupCreated  bool     `json:"group_created"`
+	ExistingSongs []int    `json:"existing_songs,omitempty"`
+	Problems      []string `json:"problems,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+type LyricSection struct {
+	Index  int      `db:"position" json:"index"`
+	Type   string   `db:"section_type" json:"type"`
+	Label  string   `db:"label" json:"label,omitempty"`
+	Number int      `db:"number" json:"number,omitempty"`
+	Lines  []string `db:"-" json:"lines"`
+	Repeat bool     `db:"repeat" json:"repeat,omitempty"`
+	// Translation holds the lines of the matching translated section in interleaved mode.
+	Translation []string `db:"-" json:"translation,omitempty"`
+}
+
+type SongTextPage struct {
+	SongID              int            `json:"id_song"`
+	Unit                string         `json:"unit"`
+	Page                int            `json:"page"`
+	Limit               int            `json:"limit"`
+	TotalPages          int            `json:"total_pages"`
+	TotalVerses         int            `json:"total_verses"`
+	TotalLines          int            `json:"total_lines"`
+	TotalChars          int            `json:"total_chars"`
+	HasNext             bool           `json:"has_next"`
+	Language            string         `json:"language,omitempty"`
+	TranslationLanguage string         `json:"translation_language,omitempty"`
+	Text                string         `json:"text"`
+	Sections            []LyricSection `json:"sections,omitempty"`
+}
+
+type SyncedWord struct {
+	TimeMs int    `json:"time_ms"`
+	Text   string `json:"text"`
+}
+
+type SyncedLine struct {
+	Index  int          `json:"index"`
+	TimeMs int          `json:"time_ms"`
+	EndMs  *int         `json:"end_ms,omitempty"`
+	Text   string       `json:"text"`
+	Words  []SyncedWord `json:"words,omitempty"`
+	// WordsEndMs is when the last word ends, given by a trailing word timestamp in enhanced LRC.
+	WordsEndMs *int `json:"words_end_ms,omitempty"`
+}
+
+type SyncedLyrics struct {
+	Tags  map[string]string `json:"tags"`
+	Lines []SyncedLine      `json:"lines"`
+}
+
+type KaraokeFrame struct {
+	SongID     int          `json:"id_song"`
+	PositionMs int          `json:"position_ms"`
+	Line       *SyncedLine  `json:"line"`
+	WordIndex  *int         `json:"word_index,omitempty"`
+	Before     []SyncedLine `json:"before"`
+	After      []SyncedLine `json:"after"`
+}
+
+type LyricTranslation struct {
+	SongID    int       `db:"id_song" json:"id_song"`
+	Language  string    `db:"language" json:"language"`
+	Lyrics    string    `db:"lyrics" json:"text"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type LyricsRevision struct {
+	SongID       int       `db:"id_song" json:"id_song"`
+	Revision     int       `db:"revision" json:"revision"`
+	Lyrics       string    `db:"lyrics" json:"text,omitempty"`
+	Lines        int       `db:"-" json:"lines"`
+	Author       string    `db:"author" json:"author"`
+	Source       string    `db:"source" json:"source"`
+	RestoredFrom *int      `db:"restored_from" json:"restored_from,omitempty"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+type DiffLine struct {
+	Op      string `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+}
+
+type LyricsDiff struct {
+	SongID  int        `json:"id_song"`
+	From    int        `json:"from"`
+	To      int        `json:"to"`
+	Added   int        `json:"added"`
+	Removed int        `json:"removed"`
+	Lines   []DiffLine `json:"lines"`
+}
+
+type Annotation struct {
+	ID        int  `db:"id_annotation" json:"id_annotation"`
+	SongID    int  `db:"id_song" json:"id_song"`
+	StartLine int  `db:"start_line" json:"start_line"`
+	EndLine   int  `db:"end_line" json:"end_line"`
+	StartChar *int `db:"start_char" json:"start_char,omitempty"`
+	EndChar   *int `db:"end_char" json:"end_char,omitempty"`
+	// Quote is the annotated text, used to find it again after the lyrics are edited.
+	Quote     string    `db:"quote" json:"quote"`
+	Body      string    `db:"body" json:"body"`
+	Author    string    `db:"author" json:"author"`
+	Orphaned  bool      `db:"orphaned" json:"orphaned"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type AnnotationAnchor struct {
+	AnnotationID int  `json:"id_annotation"`
+	StartChar    *int `json:"start_char,omitempty"`
+	EndChar      *int `json:"end_char,omitempty"`
+}
+
+type AnnotatedLine struct {
+	Line    int                `json:"line"`
+	Text    string             `json:"text"`
+	Anchors []AnnotationAnchor `json:"anchors"`
+}
+
+type AnnotatedLyrics struct {
+	SongID      int             `json:"id_song"`
+	Lines       []AnnotatedLine `json:"lines"`
+	Annotations []Annotation    `json:"annotations"`
+	Orphaned    int             `json:"orphaned"`
+}
+
+type ChordSheet struct {
+	SongID      int    `json:"id_song"`
+	Title       string `json:"title,omitempty"`
+	Key         string `json:"key,omitempty"`
+	OriginalKey string `json:"original_key,omitempty"`
+	// Semitones is how far the sheet was transposed up, from 0 to 11.
+	Semitones int      `json:"semitones"`
+	Chords    []string `json:"chords"`
+	ChordPro  string   `json:"chordpro"`
+	Text      string   `json:"text"`
+}
+
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+type VerseRhyme struct {
+	Index   int    `json:"index"`
+	Type    string `json:"type"`
+	Label   string `json:"label,omitempty"`
+	Scheme  string `json:"scheme"`
+	Pattern string `json:"pattern"`
+}
+
+type LyricsAnalysis struct {
+	SongID            int          `json:"id_song"`
+	Language          string       `json:"language"`
+	WordCount         int          `json:"word_count"`
+	UniqueWords       int          `json:"unique_words"`
+	LexicalDensity    float64      `json:"lexical_density"`
+	LineCount         int          `json:"line_count"`
+	RepeatedLineRatio float64      `json:"repeated_line_ratio"`
+	Readability       float64      `json:"readability"`
+	TopWords          []WordCount  `json:"top_words"`
+	Verses            []VerseRhyme `json:"verses"`
+}
+
+type GroupAnalysis struct {
+	GroupID              int            `json:"id_group"`
+	GroupName            string         `json:"group"`
+	Songs                int            `json:"songs"`
+	WordCount            int            `json:"word_count"`
+	UniqueWords          int            `json:"unique_words"`
+	AvgRepeatedLineRatio float64        `json:"avg_repeated_line_ratio"`
+	AvgReadability       float64        `json:"avg_readability"`
+	TopWords             []WordCount    `json:"top_words"`
+	RhymePatterns        map[string]int `json:"rhyme_patterns"`
+}
+
+// LanguageFacet is the number of songs whose lyrics are in a language.
+type LanguageFacet struct {
+	Language string `db:"language" json:"language"`
+	Count    int    `db:"count" json:"count"`
+}
+
+// ProfanityTerm is a word of the lexicon used to flag explicit lyrics; * at either end is a wildcard.
+type ProfanityTerm struct {
+	ID        int       `db:"id_term" json:"id_term"`
+	Language  string    `db:"language" json:"language"`
+	Term      string    `db:"term" json:"term"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// ProfanityRescan reports a re-scan of the library against the lexicon.
+type ProfanityRescan struct {
+	Scanned int `json:"scanned"`
+	Flagged int `json:"flagged"`
+	Cleared int `json:"cleared"`
 }
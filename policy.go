@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Enrichment policies decide what addSong does when song details can't be fetched.
+const (
+	// policyStrict fetches details synchronously and rejects the song if that fails.
+	policyStrict = "strict"
+	// policyLenient fetches details synchronously and stores the song with empty details if that fails.
+	policyLenient = "lenient"
+	// policyDeferred stores the song right away and fetches details in the background, retrying on failure.
+	policyDeferred = "deferred"
+)
+
+// defaultEnrichmentPolicy applies when a request doesn't choose a policy.
+var defaultEnrichmentPolicy = policyDeferred
+
+func parseEnrichmentPolicy(policy string) (string, error) {
+	switch policy {
+	case policyStrict, policyLenient, policyDeferred:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid enrichment policy %q, expected strict, lenient or deferred", policy)
+	}
+}
+
+func loadEnrichmentPolicy() {
+	value := os.Getenv("ENRICHMENT_POLICY")
+	if value == "" {
+		return
+	}
+	policy, err := parseEnrichmentPolicy(value)
+	if err != nil {
+		slog.Warn("Invalid ENRICHMENT_POLICY, using default", "error", err, "default", defaultEnrichmentPolicy)
+		return
+	}
+	defaultEnrichmentPolicy = policy
+	slog.Info("Enrichment policy configured", "policy", policy)
+}
+
+// setEnrichmentStatus records the outcome of enrichment; cause is nil on success.
+func setEnrichmentStatus(tx *sqlx.Tx, idSong int, status string, cause error) error {
+	var message interface{}
+	if cause != nil {
+		message = cause.Error()
+	}
+	_, err := tx.Exec(`
+		UPDATE songs
+		SET enrichment_status = $2, enrichment_error = $3,
+			enriched_at = CASE WHEN $2 = 'done' THEN now() ELSE enriched_at END,
+			enrichment_attempted_at = CASE WHEN $2 = 'pending' THEN enrichment_attempted_at ELSE now() END
+		WHERE id_song = $1`,
+		idSong, status, message)
+	return err
+}
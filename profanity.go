@@ -0,0 +1,428 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Sources of songs.explicit. Manual and provider values are never cleared by the lexicon; lexicon
+// flags are dropped again when the lyrics no longer match.
+const (
+	explicitManual   = "manual"
+	explicitProvider = "provider"
+	explicitLexicon  = "lexicon"
+)
+
+const profanityTermColumnsSQL = `id_term, language, term, created_at`
+
+var (
+	// profanityTermRe is a lexicon entry: a word, optionally with * wildcards at either end.
+	profanityTermRe = regexp.MustCompile(`^\*?[\p{L}\p{N}]+(?:'[\p{L}\p{N}]+)*\*?$`)
+	lyricWordRe     = regexp.MustCompile(`[\p{L}\p{N}]+(?:'[\p{L}\p{N}]+)*`)
+)
+
+// foldProfanity lowercases a word and spells ё as е, as it usually is in print.
+func foldProfanity(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+}
+
+// profanityLexicon holds the terms of each language, keyed by primary language subtag.
+type profanityLexicon map[string][]string
+
+// loadProfanityLexicon reads the whole lexicon.
+func loadProfanityLexicon(q sqlx.Queryer) (profanityLexicon, error) {
+	var terms []ProfanityTerm
+	if err := sqlx.Select(q, &terms, "SELECT "+profanityTermColumnsSQL+" FROM profanity_terms"); err != nil {
+		return nil, err
+	}
+	lexicon := profanityLexicon{}
+	for _, t := range terms {
+		lexicon[t.Language] = append(lexicon[t.Language], t.Term)
+	}
+	return lexicon, nil
+}
+
+// profanityMatcher tells whether words are in a lexicon: exactly, or by prefix, suffix or infix for
+// terms with wildcards.
+type profanityMatcher struct {
+	exact                       map[string]bool
+	prefixes, suffixes, infixes []string
+}
+
+// matcher returns a matcher for lyrics in the given languages. An unknown language ("") uses the
+// terms of every language, since there is no telling which apply.
+func (l profanityLexicon) matcher(languages ...string) profanityMatcher {
+	m := profanityMatcher{exact: map[string]bool{}}
+	add := func(terms []string) {
+		for _, term := range terms {
+			switch start, end := strings.HasPrefix(term, "*"), strings.HasSuffix(term, "*"); {
+			case start && end:
+				m.infixes = append(m.infixes, strings.Trim(term, "*"))
+			case start:
+				m.suffixes = append(m.suffixes, strings.TrimPrefix(term, "*"))
+			case end:
+				m.prefixes = append(m.prefixes, strings.TrimSuffix(term, "*"))
+			default:
+				m.exact[term] = true
+			}
+		}
+	}
+
+	all := len(languages) == 0
+	for _, language := range languages {
+		all = all || language == ""
+	}
+	if all {
+		for _, terms := range l {
+			add(terms)
+		}
+		return m
+	}
+	seen := map[string]bool{}
+	for _, language := range languages {
+		if primary := primaryLanguage(language); !seen[primary] {
+			seen[primary] = true
+			add(l[primary])
+		}
+	}
+	return m
+}
+
+// matches reports whether a word is profane.
+func (m profanityMatcher) matches(word string) bool {
+	word = foldProfanity(word)
+	if m.exact[word] {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range m.suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return true
+		}
+	}
+	for _, infix := range m.infixes {
+		if strings.Contains(word, infix) {
+			return true
+		}
+	}
+	return false
+}
+
+// count returns how many words of a text are profane.
+func (m profanityMatcher) count(text string) int {
+	n := 0
+	for _, word := range lyricWordRe.FindAllString(text, -1) {
+		if m.matches(word) {
+			n++
+		}
+	}
+	return n
+}
+
+// mask replaces all but the first letter of profane words with asterisks. The text keeps its
+// length in characters, so pages by character stay aligned with the original.
+func (m profanityMatcher) mask(text string) string {
+	return lyricWordRe.ReplaceAllStringFunc(text, func(word string) string {
+		if !m.matches(word) {
+			return word
+		}
+		first, size := utf8.DecodeRuneInString(word)
+		return string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+	})
+}
+
+// maskLines masks every line into a new slice.
+func (m profanityMatcher) maskLines(lines []string) []string {
+	if lines == nil {
+		return nil
+	}
+	masked := make([]string, len(lines))
+	for i, line := range lines {
+		masked[i] = m.mask(line)
+	}
+	return masked
+}
+
+// cleanParam parses the clean query parameter of the lyrics endpoints.
+func cleanParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	value := r.URL.Query().Get("clean")
+	if value == "" {
+		return false, true
+	}
+	clean, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Invalid clean parameter", "clean", value)
+		http.Error(w, "Invalid 'clean' parameter", http.StatusBadRequest)
+		return false, false
+	}
+	return clean, true
+}
+
+// lyricsMasker loads the lexicon for lyrics in the given languages, "" standing for an unknown one.
+func lyricsMasker(languages ...string) (profanityMatcher, error) {
+	lexicon, err := loadProfanityLexicon(db)
+	if err != nil {
+		return profanityMatcher{}, err
+	}
+	return lexicon.matcher(languages...), nil
+}
+
+// Outcomes of flagExplicitLyrics.
+const (
+	explicitUnchanged = ""
+	explicitFlagged   = "flagged"
+	explicitCleared   = "cleared"
+)
+
+// flagExplicitLyrics checks the current lyrics of a song against the lexicon of their language and
+// flags the song explicit when they match. A flag the lexicon set before is dropped when they no
+// longer do; manual values are left alone.
+func flagExplicitLyrics(tx *sqlx.Tx, lexicon profanityLexicon, idSong int) (string, error) {
+	var song struct {
+		Lyrics   string  `db:"lyrics"`
+		Language *string `db:"language"`
+		Explicit *bool   `db:"explicit"`
+		Source   *string `db:"explicit_source"`
+	}
+	query := `SELECT COALESCE(lyrics, '') AS lyrics, language, explicit, explicit_source FROM songs WHERE id_song = $1`
+	if err := tx.Get(&song, query, idSong); err != nil {
+		return explicitUnchanged, err
+	}
+	source := ""
+	if song.Source != nil {
+		source = *song.Source
+	}
+	if source == explicitManual {
+		return explicitUnchanged, nil
+	}
+
+	language := ""
+	if song.Language != nil {
+		language = *song.Language
+	}
+	profane := lexicon.matcher(language).count(song.Lyrics) > 0
+	// Lyrics already flagged, by the lexicon or otherwise, stay as they are.
+	switch {
+	case profane && source != explicitLexicon && !(song.Explicit != nil && *song.Explicit):
+		_, err := tx.Exec("UPDATE songs SET explicit = true, explicit_source = $2 WHERE id_song = $1", idSong, explicitLexicon)
+		return explicitFlagged, err
+	case !profane && source == explicitLexicon:
+		_, err := tx.Exec("UPDATE songs SET explicit = false, explicit_source = NULL WHERE id_song = $1", idSong)
+		return explicitCleared, err
+	}
+	return explicitUnchanged, nil
+}
+
+// updateExplicitFlag runs flagExplicitLyrics with the current lexicon.
+func updateExplicitFlag(tx *sqlx.Tx, idSong int) error {
+	lexicon, err := loadProfanityLexicon(tx)
+	if err != nil {
+		return err
+	}
+	_, err = flagExplicitLyrics(tx, lexicon, idSong)
+	return err
+}
+
+// @Summary List profanity terms
+// @Description List the lexicon used to flag explicit lyrics and mask them with clean=true. A * at the start or end of a term matches any letters there.
+// @Tags profanity
+// @Produce  json
+// @Param language query string false "Only terms of this language, e.g. en"
+// @Success 200 {array} ProfanityTerm "Lexicon terms"
+// @Failure 400 {string} string "Invalid language"
+// @Failure 500 {string} string "Failed to fetch profanity terms"
+// @Router /profanity/terms [get]
+func getProfanityTerms(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getProfanityTerms")
+
+	query := "SELECT " + profanityTermColumnsSQL + " FROM profanity_terms"
+	args := []interface{}{}
+	if value := r.URL.Query().Get("language"); value != "" {
+		language, err := normalizeLanguageTag(value)
+		if err != nil {
+			slog.Warn("Invalid language", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query += " WHERE language = $1"
+		args = append(args, primaryLanguage(language))
+	}
+	query += " ORDER BY language, term"
+
+	terms := []ProfanityTerm{}
+	if err := db.Select(&terms, query, args...); err != nil {
+		slog.Error("Failed to fetch profanity terms", "error", err)
+		http.Error(w, "Failed to fetch profanity terms", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(terms)
+}
+
+// @Summary Add a profanity term
+// @Description Add a word to the lexicon of a language, stored lowercase under the primary language subtag (en for en-GB). A * at the start or end of the term matches any letters there, e.g. fuck* or *хуй*. Songs are not re-checked until POST /profanity/rescan or their lyrics change.
+// @Tags profanity
+// @Accept  json
+// @Produce  json
+// @Param input body ProfanityTerm true "Language and term"
+// @Success 201 {object} ProfanityTerm "The added term"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 409 {string} string "Term already in the lexicon"
+// @Failure 500 {string} string "Failed to add profanity term"
+// @Router /profanity/terms [post]
+func addProfanityTerm(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request addProfanityTerm")
+
+	var input ProfanityTerm
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.Warn("Invalid JSON format", "error", err)
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	language, err := normalizeLanguageTag(input.Language)
+	if err != nil {
+		slog.Warn("Invalid language", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	term := foldProfanity(strings.TrimSpace(input.Term))
+	if !profanityTermRe.MatchString(term) {
+		slog.Warn("Invalid profanity term", "term", input.Term)
+		http.Error(w, "Invalid term: expected a single word, optionally with * at its start or end", http.StatusBadRequest)
+		return
+	}
+
+	var added ProfanityTerm
+	err = db.Get(&added, `
+		INSERT INTO profanity_terms (language, term) VALUES ($1, $2)
+		RETURNING `+profanityTermColumnsSQL, primaryLanguage(language), term)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			slog.Warn("Profanity term already exists", "language", language, "term", term)
+			http.Error(w, "Term already in the lexicon", http.StatusConflict)
+			return
+		}
+		slog.Error("Failed to add profanity term", "error", err)
+		http.Error(w, "Failed to add profanity term", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(added)
+
+	slog.Info("Profanity term added", "id_term", added.ID, "language", added.Language)
+}
+
+// @Summary Delete a profanity term
+// @Description Remove a term from the lexicon. Songs are not re-checked until POST /profanity/rescan or their lyrics change.
+// @Tags profanity
+// @Param id_term path int true "ID of the term"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Term not found"
+// @Failure 500 {string} string "Failed to delete profanity term"
+// @Router /profanity/terms/{id_term} [delete]
+func deleteProfanityTerm(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request deleteProfanityTerm")
+
+	idTerm, ok := pathID(w, r, "id_term")
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM profanity_terms WHERE id_term = $1", idTerm)
+	if err != nil {
+		slog.Error("Failed to delete profanity term", "error", err)
+		http.Error(w, "Failed to delete profanity term", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		slog.Warn("Profanity term not found", "id_term", idTerm)
+		http.Error(w, "Term not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Profanity term deleted", "id_term", idTerm)
+}
+
+// @Summary Re-scan lyrics for profanity
+// @Description Check the lyrics of every song against the current lexicon, as is done whenever lyrics are stored or updated: matching songs are flagged explicit, and flags the lexicon set before are dropped from songs that no longer match. Explicit flags set with PUT /songs are left alone.
+// @Tags profanity
+// @Produce  json
+// @Success 200 {object} ProfanityRescan "Number of songs scanned, flagged and cleared"
+// @Failure 500 {string} string "Failed to re-scan songs"
+// @Router /profanity/rescan [post]
+func rescanProfanity(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request rescanProfanity")
+
+	lexicon, err := loadProfanityLexicon(db)
+	if err != nil {
+		slog.Error("Failed to load profanity lexicon", "error", err)
+		http.Error(w, "Failed to re-scan songs", http.StatusInternalServerError)
+		return
+	}
+	var ids []int
+	if err := db.Select(&ids, "SELECT id_song FROM songs ORDER BY id_song"); err != nil {
+		slog.Error("Failed to list songs", "error", err)
+		http.Error(w, "Failed to re-scan songs", http.StatusInternalServerError)
+		return
+	}
+
+	var report ProfanityRescan
+	for _, idSong := range ids {
+		if r.Context().Err() != nil {
+			slog.Warn("Re-scan cancelled", "scanned", report.Scanned)
+			return
+		}
+		tx, err := db.Beginx()
+		if err != nil {
+			slog.Error("Failed to begin transaction", "error", err)
+			http.Error(w, "Failed to re-scan songs", http.StatusInternalServerError)
+			return
+		}
+		outcome, err := flagExplicitLyrics(tx, lexicon, idSong)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			// The song may have been deleted since it was listed.
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			slog.Error("Failed to re-scan song", "id_song", idSong, "error", err)
+			http.Error(w, "Failed to re-scan songs", http.StatusInternalServerError)
+			return
+		}
+		report.Scanned++
+		switch outcome {
+		case explicitFlagged:
+			report.Flagged++
+		case explicitCleared:
+			report.Cleared++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+
+	slog.Info("Songs re-scanned for profanity", "scanned", report.Scanned, "flagged", report.Flagged, "cleared", report.Cleared)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestProfanityMatcher(t *testing.T) {
+	lexicon := profanityLexicon{
+		"en": {"shit", "fuck*", "*fucker"},
+		"ru": {"хуй*", "хуе*", "хуя*", "нахуй*", "*пизд*", "блять"},
+	}
+	for _, tc := range []struct {
+		language string
+		word     string
+		want     bool
+	}{
+		{"en", "shit", true},
+		{"en", "Shit", true},
+		{"en", "shitake", false},
+		{"en", "fucking", true},
+		{"en", "motherfucker", true},
+		{"en", "fuckers", true},
+		{"en", "fun", false},
+		{"ru", "пиздец", true},
+		{"ru", "распиздяй", true},
+		{"ru", "хуёвый", true},
+		{"ru", "нахуй", true},
+		{"ru", "блять", true},
+		{"ru", "Блять", true},
+		{"ru", "страхует", false},
+		{"ru", "застрахуем", false},
+		{"ru", "страхуя", false},
+		{"ru", "страхуй", false},
+		// Terms of another language don't apply, but all of them do for an unknown one.
+		{"en", "пиздец", false},
+		{"", "пиздец", true},
+	} {
+		if got := lexicon.matcher(tc.language).matches(tc.word); got != tc.want {
+			t.Errorf("matches(%q) in %q = %v, want %v", tc.word, tc.language, got, tc.want)
+		}
+	}
+}
+
+func TestProfanityMask(t *testing.T) {
+	m := profanityLexicon{"en": {"shit", "fuck*"}, "ru": {"хуе*"}}.matcher("")
+	for _, tc := range []struct {
+		text  string
+		want  string
+		count int
+	}{
+		{"Oh shit, fucking rain", "Oh s***, f****** rain", 2},
+		{"Хуёвый день", "Х***** день", 1},
+		{"Он застрахует дом", "Он застрахует дом", 0},
+		{"Clean lyrics", "Clean lyrics", 0},
+	} {
+		got := m.mask(tc.text)
+		if got != tc.want {
+			t.Errorf("mask(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+		if utf8.RuneCountInString(got) != utf8.RuneCountInString(tc.text) {
+			t.Errorf("mask(%q) changed the length to %d runes", tc.text, utf8.RuneCountInString(got))
+		}
+		if count := m.count(tc.text); count != tc.count {
+			t.Errorf("count(%q) = %d, want %d", tc.text, count, tc.count)
+		}
+	}
+}
+
+// testDB connects to the database named by the DB_* variables and migrates it, skipping the test
+// when none is configured or reachable.
+func testDB(t *testing.T) {
+	t.Helper()
+	if os.Getenv("DB_host") == "" {
+		t.Skip("DB_host is not set")
+	}
+	conn, err := connectDB()
+	if err != nil {
+		t.Skip(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		t.Skip(err)
+	}
+	if err := runMigrations(conn); err != nil {
+		t.Fatal(err)
+	}
+	db = conn
+	t.Cleanup(func() { conn.Close() })
+}
+
+func TestExplicitFlagFollowsLyrics(t *testing.T) {
+	testDB(t)
+
+	var idGroup, idSong int
+	if err := db.Get(&idGroup, "INSERT INTO musicGroups (groupName) VALUES ('Profanity Test') RETURNING id_group"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM musicGroups WHERE id_group = $1", idGroup) })
+	err := db.Get(&idSong, "INSERT INTO songs (id_group, song, language) VALUES ($1, 'Test', 'en') RETURNING id_song", idGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// setLyrics stores lyrics, checking them against the lexicon when check is set, as edits do.
+	setLyrics := func(lyrics string, check bool) {
+		t.Helper()
+		tx, err := db.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if _, err := tx.Exec("UPDATE songs SET lyrics = $2 WHERE id_song = $1", idSong, lyrics); err != nil {
+			t.Fatal(err)
+		}
+		if check {
+			if err := updateExplicitFlag(tx, idSong); err != nil {
+				t.Fatalf("updateExplicitFlag: %v", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(explicit bool, source *string) {
+		t.Helper()
+		var song struct {
+			Explicit bool    `db:"explicit"`
+			Source   *string `db:"explicit_source"`
+		}
+		if err := db.Get(&song, "SELECT explicit, explicit_source FROM songs WHERE id_song = $1", idSong); err != nil {
+			t.Fatal(err)
+		}
+		if song.Explicit != explicit || (song.Source == nil) != (source == nil) || (source != nil && *song.Source != *source) {
+			t.Fatalf("got explicit %v, source %v; want %v, %v", song.Explicit, song.Source, explicit, source)
+		}
+	}
+	lexicon := explicitLexicon
+
+	setLyrics("What the fuck", true)
+	expect(true, &lexicon)
+	setLyrics("What the heck", true)
+	expect(false, nil)
+
+	// Lyrics cleaned without a check are cleared by a rescan.
+	setLyrics("What the fuck", true)
+	setLyrics("What the heck", false)
+	recorder := httptest.NewRecorder()
+	rescanProfanity(recorder, httptest.NewRequest(http.MethodPost, "/profanity/rescan", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("rescan: status %d: %s", recorder.Code, recorder.Body)
+	}
+	var report ProfanityRescan
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Cleared < 1 {
+		t.Fatalf("rescan cleared %d songs", report.Cleared)
+	}
+	expect(false, nil)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// detailProvider is a source of song details for enrichment.
+type detailProvider interface {
+	Name() string
+	// Fetch returns errSongDetailsNotFound when the provider knows nothing about the song.
+	Fetch(ctx context.Context, group, song string) (SongDetail, error)
+}
+
+// enricher fetches song details from the configured providers; it is shared by every handler.
+var enricher detailProvider
+
+// detailField describes one SongDetail field for merging and diffing.
+type detailField struct {
+	name  string
+	isSet func(d *SongDetail) bool
+	copy  func(dst *SongDetail, src *SongDetail)
+	value func(d *SongDetail) interface{}
+}
+
+var detailFields = []detailField{
+	{"release_date", func(d *SongDetail) bool { return d.ReleaseDate != "" },
+		func(dst, src *SongDetail) { dst.ReleaseDate = src.ReleaseDate },
+		func(d *SongDetail) interface{} { return d.ReleaseDate }},
+	{"text", func(d *SongDetail) bool { return d.Lyrics != "" },
+		func(dst, src *SongDetail) { dst.Lyrics = src.Lyrics },
+		func(d *SongDetail) interface{} { return d.Lyrics }},
+	{"link", func(d *SongDetail) bool { return d.Link != "" },
+		func(dst, src *SongDetail) { dst.Link = src.Link },
+		func(d *SongDetail) interface{} { return d.Link }},
+	{"duration", func(d *SongDetail) bool { return d.Duration != nil },
+		func(dst, src *SongDetail) { dst.Duration = src.Duration },
+		func(d *SongDetail) interface{} { return deref(d.Duration) }},
+	{"bpm", func(d *SongDetail) bool { return d.BPM != nil },
+		func(dst, src *SongDetail) { dst.BPM = src.BPM },
+		func(d *SongDetail) interface{} { return deref(d.BPM) }},
+	{"key", func(d *SongDetail) bool { return d.Key != nil },
+		func(dst, src *SongDetail) { dst.Key = src.Key },
+		func(d *SongDetail) interface{} { return deref(d.Key) }},
+	{"time_signature", func(d *SongDetail) bool { return d.TimeSignature != nil },
+		func(dst, src *SongDetail) { dst.TimeSignature = src.TimeSignature },
+		func(d *SongDetail) interface{} { return deref(d.TimeSignature) }},
+	{"explicit", func(d *SongDetail) bool { return d.Explicit != nil },
+		func(dst, src *SongDetail) { dst.Explicit = src.Explicit },
+		func(d *SongDetail) interface{} { return deref(d.Explicit) }},
+}
+
+// deref returns the value p points to, or nil so unset fields compare and encode as null.
+func deref[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// fieldSources records which provider supplied each detail field; it is stored as JSONB.
+type fieldSources map[string]string
+
+func (s fieldSources) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *fieldSources) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into fieldSources", src)
+	}
+}
+
+// providerChain asks every provider and merges their answers field by field. For each field the
+// first provider in priority order that has a value wins; priorities default to the chain order.
+type providerChain struct {
+	providers     []detailProvider
+	fieldPriority map[string][]string
+}
+
+func newProviderChainFromEnv() *providerChain {
+	chain := &providerChain{fieldPriority: make(map[string][]string)}
+
+	cache, err := newDetailCacheFromEnv()
+	if err != nil {
+		slog.Warn("Response cache disabled", "error", err)
+	}
+	sharedDetailCache = cache
+
+	names := os.Getenv("ENRICHMENT_PROVIDERS")
+	if names == "" {
+		names = "external_api"
+	}
+	for _, name := range strings.Split(names, ",") {
+		provider, err := newProviderFromEnv(strings.TrimSpace(name))
+		if err != nil {
+			slog.Warn("Skipping enrichment provider", "provider", name, "error", err)
+			continue
+		}
+		chain.providers = append(chain.providers, provider)
+	}
+
+	for _, field := range detailFields {
+		if priority := os.Getenv("ENRICHMENT_PRIORITY_" + strings.ToUpper(field.name)); priority != "" {
+			for _, name := range strings.Split(priority, ",") {
+				chain.fieldPriority[field.name] = append(chain.fieldPriority[field.name], strings.TrimSpace(name))
+			}
+		}
+	}
+
+	providerNames := make([]string, 0, len(chain.providers))
+	for _, provider := range chain.providers {
+		providerNames = append(providerNames, provider.Name())
+	}
+	slog.Info("Enrichment providers configured", "providers", providerNames)
+	return chain
+}
+
+func newProviderFromEnv(name string) (detailProvider, error) {
+	switch name {
+	case "external_api":
+		return withCache(newEnrichmentClientFromEnv(name, "EXTERNAL_API")), nil
+	case "secondary_api":
+		return withCache(newEnrichmentClientFromEnv(name, "SECONDARY_API")), nil
+	case "lyrics_dir":
+		return newLyricsDirProvider(name, os.Getenv("LYRICS_DIR"))
+	case "catalogue":
+		return newCatalogueProvider(name, os.Getenv("CATALOGUE_FILE"))
+	default:
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+}
+
+func (c *providerChain) Name() string {
+	return "chain"
+}
+
+// partialFetchError comes with merged details when some providers failed transiently while others
+// answered. The details can be stored, but the song should be fetched again for the missing fields.
+type partialFetchError struct {
+	Providers []string
+	Err       error
+}
+
+func (e *partialFetchError) Error() string {
+	return fmt.Sprintf("providers %s failed: %v", strings.Join(e.Providers, ", "), e.Err)
+}
+
+func (e *partialFetchError) Unwrap() error {
+	return e.Err
+}
+
+// hasDetails reports whether a fetch returned details worth storing: it succeeded, or only partly failed.
+func hasDetails(err error) bool {
+	var partial *partialFetchError
+	return err == nil || errors.As(err, &partial)
+}
+
+// Fetch queries all providers concurrently. Providers that fail for good are skipped as long as another
+// one answers; transient failures are reported with the merged details as a partialFetchError so the
+// song is fetched again. If no provider answers, a transient error wins over "not found" so the job is retried.
+func (c *providerChain) Fetch(ctx context.Context, group, song string) (SongDetail, error) {
+	details := make([]*SongDetail, len(c.providers))
+	errs := make([]error, len(c.providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range c.providers {
+		wg.Add(1)
+		go func(i int, provider detailProvider) {
+			defer wg.Done()
+			detail, err := provider.Fetch(ctx, group, song)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			details[i] = &detail
+		}(i, provider)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return SongDetail{}, err
+	}
+
+	byName := make(map[string]*SongDetail)
+	var transientErr, retryErr error
+	var failed []string
+	notFound := false
+	for i, provider := range c.providers {
+		switch err := errs[i]; {
+		case err == nil:
+			byName[provider.Name()] = details[i]
+		case errors.Is(err, errSongDetailsNotFound):
+			notFound = true
+		case errors.Is(err, errEnrichmentNotConfigured):
+		default:
+			slog.Warn("Enrichment provider failed", "provider", provider.Name(), "error", err)
+			if transientErr == nil {
+				transientErr = err
+			}
+			if isRetryable(err) {
+				failed = append(failed, provider.Name())
+				if retryErr == nil {
+					retryErr = err
+				}
+			}
+		}
+	}
+
+	if len(byName) == 0 {
+		switch {
+		case transientErr != nil:
+			return SongDetail{}, transientErr
+		case notFound:
+			return SongDetail{}, errSongDetailsNotFound
+		default:
+			return SongDetail{}, errEnrichmentNotConfigured
+		}
+	}
+
+	merged := SongDetail{Sources: fieldSources{}}
+	for _, provider := range c.providers {
+		if detail := byName[provider.Name()]; detail != nil {
+			for _, problem := range detail.Problems {
+				merged.Problems = append(merged.Problems, provider.Name()+": "+problem)
+			}
+		}
+	}
+	for _, field := range detailFields {
+		for _, name := range c.priority(field.name) {
+			detail := byName[name]
+			if detail == nil || !field.isSet(detail) {
+				continue
+			}
+			field.copy(&merged, detail)
+			merged.Sources[field.name] = name
+			break
+		}
+	}
+	if retryErr != nil {
+		return merged, &partialFetchError{Providers: failed, Err: retryErr}
+	}
+	return merged, nil
+}
+
+func (c *providerChain) priority(field string) []string {
+	if priority, ok := c.fieldPriority[field]; ok {
+		return priority
+	}
+	names := make([]string, 0, len(c.providers))
+	for _, provider := range c.providers {
+		names = append(names, provider.Name())
+	}
+	return names
+}
+
+// normalizeSongKey makes group and song names comparable regardless of case and spacing.
+func normalizeSongKey(group, song string) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	return normalize(group) + "\x00" + normalize(song)
+}
+
+// lyricsDirProvider reads lyrics from text files laid out as <dir>/<group>/<song>.txt
+// or <dir>/<group> - <song>.txt. The directory is indexed once at startup.
+type lyricsDirProvider struct {
+	name  string
+	files map[string]string
+}
+
+func newLyricsDirProvider(name, dir string) (*lyricsDirProvider, error) {
+	if dir == "" {
+		return nil, errors.New("LYRICS_DIR not configured")
+	}
+
+	provider := &lyricsDirProvider{name: name, files: make(map[string]string)}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".txt") {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+		base := strings.TrimSuffix(rel, filepath.Ext(rel))
+		group, song, ok := strings.Cut(filepath.ToSlash(base), "/")
+		if !ok {
+			group, song, ok = strings.Cut(base, " - ")
+		}
+		if ok {
+			provider.files[normalizeSongKey(group, song)] = path
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index lyrics directory: %w", err)
+	}
+
+	slog.Info("Lyrics directory indexed", "dir", dir, "files", len(provider.files))
+	return provider, nil
+}
+
+func (p *lyricsDirProvider) Name() string {
+	return p.name
+}
+
+func (p *lyricsDirProvider) Fetch(ctx context.Context, group, song string) (SongDetail, error) {
+	path, ok := p.files[normalizeSongKey(group, song)]
+	if !ok {
+		return SongDetail{}, errSongDetailsNotFound
+	}
+
+	lyrics, err := os.ReadFile(path)
+	if err != nil {
+		return SongDetail{}, err
+	}
+	return SongDetail{Lyrics: string(lyrics)}, nil
+}
+
+// catalogueProvider serves details from a static JSON file: an array of objects with "group", "song"
+// and any SongDetail fields. The file is loaded once at startup.
+type catalogueProvider struct {
+	name    string
+	entries map[string]SongDetail
+}
+
+type catalogueEntry struct {
+	Group string `json:"group"`
+	Song  string `json:"song"`
+	SongDetail
+}
+
+func newCatalogueProvider(name, path string) (*catalogueProvider, error) {
+	if path == "" {
+		return nil, errors.New("CATALOGUE_FILE not configured")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalogue: %w", err)
+	}
+
+	var entries []catalogueEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse catalogue: %w", err)
+	}
+
+	provider := &catalogueProvider{name: name, entries: make(map[string]SongDetail, len(entries))}
+	for _, entry := range entries {
+		entry.SongDetail.Sources = nil
+		provider.entries[normalizeSongKey(entry.Group, entry.Song)] = entry.SongDetail
+	}
+
+	slog.Info("Catalogue loaded", "file", path, "songs", len(provider.entries))
+	return provider, nil
+}
+
+func (p *catalogueProvider) Name() string {
+	return p.name
+}
+
+func (p *catalogueProvider) Fetch(ctx context.Context, group, song string) (SongDetail, error) {
+	detail, ok := p.entries[normalizeSongKey(group, song)]
+	if !ok {
+		return SongDetail{}, errSongDetailsNotFound
+	}
+	return detail, nil
+}
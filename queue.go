@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	enrichmentPending  = "pending"
+	enrichmentDone     = "done"
+	enrichmentFailed   = "failed"
+	enrichmentNotFound = "not_found"
+
+	maxEnrichmentWait = time.Minute
+)
+
+// queue runs song enrichment in the background; it is shared by every handler.
+var queue *enrichmentQueue
+
+// enrichmentQueue is a pool of workers processing the Postgres-backed enrichment_jobs table.
+// Jobs survive restarts, and a job whose worker died is picked up again once its lock expires.
+type enrichmentQueue struct {
+	workers      int
+	maxAttempts  int
+	pollInterval time.Duration
+	lockTimeout  time.Duration
+	retryBackoff time.Duration
+
+	wake chan struct{}
+
+	mu      sync.Mutex
+	waiters map[int][]chan struct{}
+}
+
+type enrichmentJob struct {
+	ID        int    `db:"id_job"`
+	SongID    int    `db:"id_song"`
+	Attempts  int    `db:"attempts"`
+	GroupName string `db:"group"`
+	SongName  string `db:"song"`
+	Bypass    bool   `db:"bypass_cache"`
+}
+
+func newEnrichmentQueueFromEnv() *enrichmentQueue {
+	q := &enrichmentQueue{
+		workers:      envInt("ENRICHMENT_WORKERS", 4),
+		maxAttempts:  envInt("ENRICHMENT_MAX_ATTEMPTS", 5),
+		pollInterval: envDuration("ENRICHMENT_POLL_INTERVAL", 2*time.Second),
+		lockTimeout:  envDuration("ENRICHMENT_LOCK_TIMEOUT", 2*time.Minute),
+		retryBackoff: envDuration("ENRICHMENT_RETRY_BACKOFF", 30*time.Second),
+		wake:         make(chan struct{}, 1),
+		waiters:      make(map[int][]chan struct{}),
+	}
+	slog.Info("Enrichment queue configured", "workers", q.workers, "max_attempts", q.maxAttempts)
+	return q
+}
+
+// Start launches the workers; they stop when ctx is cancelled.
+func (q *enrichmentQueue) Start(ctx context.Context) {
+	for i := 0; i < q.workers; i++ {
+		go q.work(ctx, i)
+	}
+}
+
+// Enqueue schedules enrichment of a song inside tx and marks it pending. A job a worker holds right
+// now is left to it and only flagged to run again once it is through. Refreshes set bypass so the
+// job asks the providers instead of the lookup cache.
+func (q *enrichmentQueue) Enqueue(tx *sqlx.Tx, idSong int, bypass bool) error {
+	_, err := tx.Exec(`
+		INSERT INTO enrichment_jobs AS j (id_song, bypass_cache) VALUES ($1, $2)
+		ON CONFLICT (id_song) DO UPDATE SET
+			bypass_cache = j.bypass_cache OR EXCLUDED.bypass_cache,
+			attempts = CASE WHEN j.locked_until >= now() THEN j.attempts ELSE 0 END,
+			run_at = CASE WHEN j.locked_until >= now() THEN j.run_at ELSE now() END,
+			last_error = CASE WHEN j.locked_until >= now() THEN j.last_error END,
+			locked_until = CASE WHEN j.locked_until >= now() THEN j.locked_until END,
+			rerun = COALESCE(j.locked_until >= now(), false)`,
+		idSong, bypass)
+	if err != nil {
+		return err
+	}
+	return setEnrichmentStatus(tx, idSong, enrichmentPending, nil)
+}
+
+// Notify wakes an idle worker after a job was committed.
+func (q *enrichmentQueue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Subscribe returns a channel closed when a local worker finishes the song's enrichment, and a function
+// releasing it. Workers in other instances don't signal, so callers re-read the status either way.
+func (q *enrichmentQueue) Subscribe(idSong int) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+	q.mu.Lock()
+	q.waiters[idSong] = append(q.waiters[idSong], ch)
+	q.mu.Unlock()
+
+	return ch, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		waiters := q.waiters[idSong]
+		for i, waiter := range waiters {
+			if waiter == ch {
+				q.waiters[idSong] = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(q.waiters[idSong]) == 0 {
+			delete(q.waiters, idSong)
+		}
+	}
+}
+
+func (q *enrichmentQueue) finished(idSong int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, ch := range q.waiters[idSong] {
+		close(ch)
+	}
+	delete(q.waiters, idSong)
+}
+
+func (q *enrichmentQueue) work(ctx context.Context, worker int) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			job, err := q.claim()
+			if err == sql.ErrNoRows {
+				break
+			}
+			if err != nil {
+				slog.Error("Failed to claim enrichment job", "worker", worker, "error", err)
+				break
+			}
+			q.process(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim locks the next due job so no other worker, here or in another instance, takes it.
+func (q *enrichmentQueue) claim() (enrichmentJob, error) {
+	var job enrichmentJob
+	query := `
+		WITH next AS (
+			SELECT id_job FROM enrichment_jobs
+			WHERE run_at <= now() AND (locked_until IS NULL OR locked_until < now())
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE enrichment_jobs j
+		SET attempts = j.attempts + 1, locked_until = now() + $1 * interval '1 millisecond'
+		FROM next, songs s, musicGroups g
+		WHERE j.id_job = next.id_job AND s.id_song = j.id_song AND g.id_group = s.id_group
+		RETURNING j.id_job, j.id_song, j.attempts, g.groupName AS group, s.song, j.bypass_cache`
+	err := db.Get(&job, query, q.lockTimeout.Milliseconds())
+	return job, err
+}
+
+func (q *enrichmentQueue) process(ctx context.Context, job enrichmentJob) {
+	slog.Debug("Enriching song", "id_song", job.SongID, "attempt", job.Attempts)
+
+	fetchCtx := ctx
+	if job.Bypass {
+		fetchCtx = bypassCache(ctx)
+	}
+	detail, err := enricher.Fetch(fetchCtx, job.GroupName, job.SongName)
+	switch {
+	case err == nil:
+		err = q.complete(job, detail)
+	case hasDetails(err) && job.Attempts < q.maxAttempts:
+		slog.Warn("Song partly enriched, the rest will be retried", "id_song", job.SongID, "attempts", job.Attempts, "error", err)
+		err = q.completePartly(job, detail, err)
+	case hasDetails(err):
+		// Out of attempts: keep what the other providers returned.
+		detail.Problems = append(detail.Problems, err.Error())
+		err = q.complete(job, detail)
+	case errors.Is(err, errSongDetailsNotFound):
+		slog.Warn("External API has no details for the song", "id_song", job.SongID)
+		err = q.finish(job, enrichmentNotFound, err)
+	case ctx.Err() != nil:
+		// Shutting down; the lock expires and another worker retries the job.
+		return
+	case job.Attempts >= q.maxAttempts || !isRetryable(err):
+		slog.Warn("Song enrichment failed", "id_song", job.SongID, "attempts", job.Attempts, "error", err)
+		err = q.finish(job, enrichmentFailed, err)
+	default:
+		slog.Warn("Song enrichment will be retried", "id_song", job.SongID, "attempts", job.Attempts, "error", err)
+		err = q.retry(job, err)
+	}
+	if err != nil {
+		slog.Error("Failed to record enrichment result", "id_song", job.SongID, "error", err)
+	}
+}
+
+// complete stores fetched details and removes the job.
+func (q *enrichmentQueue) complete(job enrichmentJob, detail SongDetail) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := storeSongDetail(tx, job.SongID, detail); err != nil {
+		return err
+	}
+	rerun, err := q.release(tx, job)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	slog.Info("Song enriched successfully", "id_song", job.SongID)
+	q.settled(job.SongID, rerun)
+	return nil
+}
+
+// completePartly stores the details some providers returned and retries the job for the rest;
+// the song stays pending meanwhile.
+func (q *enrichmentQueue) completePartly(job enrichmentJob, detail SongDetail, cause error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := storeSongDetail(tx, job.SongID, detail); err != nil {
+		return err
+	}
+	if err := setEnrichmentStatus(tx, job.SongID, enrichmentPending, cause); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return q.retry(job, cause)
+}
+
+// finish records a final status and removes the job.
+func (q *enrichmentQueue) finish(job enrichmentJob, status string, cause error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setEnrichmentStatus(tx, job.SongID, status, cause); err != nil {
+		return err
+	}
+	rerun, err := q.release(tx, job)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	q.settled(job.SongID, rerun)
+	return nil
+}
+
+// release removes a processed job, or reschedules it right away when the song was enqueued again
+// while it ran; the song is then pending once more.
+func (q *enrichmentQueue) release(tx *sqlx.Tx, job enrichmentJob) (bool, error) {
+	result, err := tx.Exec("DELETE FROM enrichment_jobs WHERE id_job = $1 AND NOT rerun", job.ID)
+	if err != nil {
+		return false, err
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		return false, nil
+	}
+	_, err = tx.Exec(`
+		UPDATE enrichment_jobs
+		SET attempts = 0, run_at = now(), locked_until = NULL, last_error = NULL, rerun = false
+		WHERE id_job = $1`,
+		job.ID)
+	if err != nil {
+		return false, err
+	}
+	return true, setEnrichmentStatus(tx, job.SongID, enrichmentPending, nil)
+}
+
+// settled wakes those waiting for the song, or a worker when its job has to run again.
+func (q *enrichmentQueue) settled(idSong int, rerun bool) {
+	if rerun {
+		slog.Debug("Song enqueued again while being enriched", "id_song", idSong)
+		q.Notify()
+		return
+	}
+	q.finished(idSong)
+}
+
+// retry releases the job and schedules it again after a linearly growing delay, or later if the upstream asked to.
+// A job enqueued again meanwhile starts over right away.
+func (q *enrichmentQueue) retry(job enrichmentJob, cause error) error {
+	delay := q.retryBackoff * time.Duration(job.Attempts)
+	var statusErr *upstreamStatusError
+	if errors.As(cause, &statusErr) && statusErr.RetryAfter > delay {
+		delay = statusErr.RetryAfter
+	}
+	_, err := db.Exec(`
+		UPDATE enrichment_jobs
+		SET run_at = CASE WHEN rerun THEN now() ELSE now() + $2 * interval '1 millisecond' END,
+			attempts = CASE WHEN rerun THEN 0 ELSE attempts END,
+			locked_until = NULL, last_error = $3, rerun = false
+		WHERE id_job = $1`,
+		job.ID, delay.Milliseconds(), cause.Error())
+	return err
+}
+
+// sanitizeSongDetail drops fetched values that don't validate, recording why in Problems, and
+// normalizes the rest, so what is stored and what a refresh diff shows are the same.
+func sanitizeSongDetail(idSong int, detail *SongDetail) {
+	if detail.Sources == nil {
+		detail.Sources = fieldSources{}
+	}
+	detail.Lyrics = normalizeLyrics(detail.Lyrics)
+	drop := func(err error, fields ...string) {
+		slog.Warn("Ignoring song details from external API", "id_song", idSong, "fields", fields, "error", err)
+		detail.Problems = append(detail.Problems, err.Error())
+		for _, field := range fields {
+			delete(detail.Sources, field)
+		}
+	}
+
+	detail.Precision = ""
+	if detail.ReleaseDate != "" {
+		date, precision, err := parseReleaseDate(detail.ReleaseDate)
+		if err != nil {
+			detail.ReleaseDate = ""
+			drop(err, "release_date")
+		} else {
+			detail.ReleaseDate, detail.Precision = formatReleaseDate(date, precision), precision
+		}
+	}
+	if err := detail.AudioMetadata.validate(); err != nil {
+		detail.AudioMetadata = AudioMetadata{}
+		drop(err, "duration", "bpm", "key", "time_signature", "explicit")
+	}
+	if detail.Link != "" {
+		link, _, err := parseLink(detail.Link)
+		if err != nil {
+			drop(err, "link")
+		}
+		detail.Link = link
+	}
+}
+
+// storeSongDetail writes fetched details to a song and marks its enrichment done. Only fields the
+// providers supplied are overwritten, so refreshing a song never wipes data the upstream has since lost.
+// Values that had to be dropped are kept as the enrichment error.
+func storeSongDetail(tx *sqlx.Tx, idSong int, detail SongDetail) error {
+	sanitizeSongDetail(idSong, &detail)
+	releaseDate, precision, _ := releaseDateArgs(detail.ReleaseDate)
+
+	query := `
+		UPDATE songs
+		SET release_date = COALESCE($2, release_date),
+			release_date_precision = COALESCE($3, release_date_precision),
+			lyrics = COALESCE(NULLIF($4, ''), lyrics),
+			duration = COALESCE($5, duration),
+			bpm = COALESCE($6, bpm),
+			musical_key = COALESCE($7, musical_key),
+			time_signature = COALESCE($8, time_signature),
+			explicit = COALESCE($9, explicit),
+			explicit_source = CASE WHEN $9::boolean IS NULL THEN explicit_source ELSE $11 END,
+			detail_sources = detail_sources || $10
+		WHERE id_song = $1`
+	_, err := tx.Exec(query, idSong, releaseDate, precision, detail.Lyrics,
+		detail.Duration, detail.BPM, detail.Key, detail.TimeSignature, detail.Explicit, detail.Sources, explicitProvider)
+	if err != nil {
+		return err
+	}
+
+	if detail.Lyrics != "" {
+		if err := syncLyricSections(tx, idSong); err != nil {
+			return err
+		}
+		if err := updateLyricsLanguage(tx, idSong); err != nil {
+			return err
+		}
+		if err := updateExplicitFlag(tx, idSong); err != nil {
+			return err
+		}
+		// The provider that supplied the lyrics is their author.
+		author := detail.Sources["text"]
+		if author == "" {
+			author = revisionEnrichment
+		}
+		if _, _, err := recordLyricsRevision(tx, idSong, author, revisionEnrichment, 0); err != nil {
+			return err
+		}
+	}
+	if detail.Link != "" {
+		if err := insertLink(tx, idSong, detail.Link); err != nil {
+			return err
+		}
+	}
+
+	var problems error
+	if len(detail.Problems) > 0 {
+		problems = fmt.Errorf("ignored invalid values: %s", strings.Join(detail.Problems, "; "))
+	}
+	return setEnrichmentStatus(tx, idSong, enrichmentDone, problems)
+}
+
+// @Summary Song enrichment status
+// @Description Get the status of fetching details from the external API: pending, done, failed or not_found. With 'wait' the request blocks until the song is no longer pending or the wait expires.
+// @Tags enrichment
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param wait query string false "Long-poll duration, e.g. 30s (at most 1m)"
+// @Success 200 {object} EnrichmentStatus "Enrichment status"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch enrichment status"
+// @Router /songs/{id_song}/enrichment [get]
+func getEnrichmentStatus(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getEnrichmentStatus")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var wait time.Duration
+	if waitStr := r.URL.Query().Get("wait"); waitStr != "" {
+		var err error
+		wait, err = time.ParseDuration(waitStr)
+		if err != nil || wait < 0 {
+			slog.Warn("Invalid wait parameter", "wait", waitStr)
+			http.Error(w, "Invalid wait parameter", http.StatusBadRequest)
+			return
+		}
+		if wait > maxEnrichmentWait {
+			wait = maxEnrichmentWait
+		}
+	}
+
+	// Subscribe before reading so a job finishing in between isn't missed.
+	done, release := queue.Subscribe(idSong)
+	defer release()
+
+	status, err := fetchEnrichmentStatus(idSong)
+	if err == nil && status.Status == enrichmentPending && wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-done:
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+		timer.Stop()
+		status, err = fetchEnrichmentStatus(idSong)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch enrichment status", "error", err)
+		http.Error(w, "Failed to fetch enrichment status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+
+	slog.Debug("Enrichment status fetched successfully", "id_song", idSong, "status", status.Status)
+}
+
+func fetchEnrichmentStatus(idSong int) (EnrichmentStatus, error) {
+	var status EnrichmentStatus
+	query := `
+		SELECT s.id_song, s.enrichment_status, COALESCE(j.last_error, s.enrichment_error, '') AS error,
+			COALESCE(j.attempts, 0) AS attempts, j.run_at AS next_attempt
+		FROM songs s
+		LEFT JOIN enrichment_jobs j ON j.id_song = s.id_song
+		WHERE s.id_song = $1`
+	err := db.Get(&status, query, idSong)
+	return status, err
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// outboundLimiters lists the limiter of every enrichment client, for metrics.
+var (
+	outboundLimitersMu sync.Mutex
+	outboundLimiters   []*outboundLimiter
+)
+
+// outboundLimiter paces the calls of one enrichment client. Every handler and worker goes through
+// the same client, so the limits hold for the whole process: a token bucket caps the request rate,
+// a semaphore caps requests in flight, and a 429 with Retry-After pauses everyone until it passes,
+// for no longer than the client's maximum Retry-After.
+type outboundLimiter struct {
+	name        string
+	rate        float64
+	burst       int
+	maxInFlight int
+	slots       chan struct{}
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+
+	queued    atomic.Int64
+	inFlight  atomic.Int64
+	requests  atomic.Int64
+	throttled atomic.Int64
+	waitTotal atomic.Int64
+	waitMax   atomic.Int64
+}
+
+// newOutboundLimiter creates a limiter; a non-positive rate or maxInFlight disables that limit.
+func newOutboundLimiter(name string, rate float64, burst, maxInFlight int) *outboundLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &outboundLimiter{name: name, rate: rate, burst: burst, maxInFlight: maxInFlight, tokens: float64(burst), last: time.Now()}
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+
+	outboundLimitersMu.Lock()
+	outboundLimiters = append(outboundLimiters, l)
+	outboundLimitersMu.Unlock()
+	return l
+}
+
+// acquire waits until a request may be sent and returns a function releasing its slot.
+func (l *outboundLimiter) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	l.queued.Add(1)
+	defer l.queued.Add(-1)
+
+	for {
+		delay := l.pauseLeft()
+		if delay <= 0 {
+			break
+		}
+		if err := sleepCtx(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	if delay := l.reserve(); delay > 0 {
+		if err := sleepCtx(ctx, delay); err != nil {
+			l.unreserve()
+			return nil, err
+		}
+	}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			l.unreserve()
+			return nil, ctx.Err()
+		}
+	}
+
+	waited := time.Since(start)
+	l.waitTotal.Add(int64(waited))
+	for {
+		max := l.waitMax.Load()
+		if int64(waited) <= max || l.waitMax.CompareAndSwap(max, int64(waited)) {
+			break
+		}
+	}
+	l.requests.Add(1)
+	l.inFlight.Add(1)
+
+	return func() {
+		l.inFlight.Add(-1)
+		if l.slots != nil {
+			<-l.slots
+		}
+	}, nil
+}
+
+// reserve takes a token, borrowing from the future when the bucket is empty, and returns how
+// long to wait until the borrowed token would have been there.
+func (l *outboundLimiter) reserve() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+func (l *outboundLimiter) unreserve() {
+	if l.rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+// pause stops all calls for d, e.g. after the upstream answered 429 with Retry-After.
+func (l *outboundLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+		slog.Warn("External API asked to slow down", "provider", l.name, "retry_after", d)
+	}
+}
+
+func (l *outboundLimiter) pauseLeft() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Until(l.pausedUntil)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date; 0 means absent.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+func (l *outboundLimiter) stats() LimiterStats {
+	l.mu.Lock()
+	pausedUntil := l.pausedUntil
+	l.mu.Unlock()
+
+	stats := LimiterStats{
+		Provider:    l.name,
+		RateLimit:   l.rate,
+		Burst:       l.burst,
+		MaxInFlight: l.maxInFlight,
+		Queued:      l.queued.Load(),
+		InFlight:    l.inFlight.Load(),
+		Requests:    l.requests.Load(),
+		Throttled:   l.throttled.Load(),
+		MaxWaitMs:   time.Duration(l.waitMax.Load()).Milliseconds(),
+	}
+	if stats.Requests > 0 {
+		stats.AvgWaitMs = time.Duration(l.waitTotal.Load() / stats.Requests).Milliseconds()
+	}
+	if pausedUntil.After(time.Now()) {
+		stats.PausedUntil = &pausedUntil
+	}
+	return stats
+}
+
+// @Summary Outbound rate limit metrics
+// @Description Get the limits of every external API client with the number of queued and in-flight requests, time spent waiting for a slot, and 429 answers received since startup.
+// @Tags enrichment
+// @Produce  json
+// @Success 200 {array} LimiterStats "Limiter metrics per provider"
+// @Router /enrichment/limits [get]
+func getLimiterStats(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getLimiterStats")
+
+	outboundLimitersMu.Lock()
+	stats := make([]LimiterStats, 0, len(outboundLimiters))
+	for _, limiter := range outboundLimiters {
+		stats = append(stats, limiter.stats())
+	}
+	outboundLimitersMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcomes of refreshing one song.
+const (
+	refreshChanged   = "changed"
+	refreshUnchanged = "unchanged"
+	refreshQueued    = "queued"
+
+	defaultRefreshLimit = 50
+	maxRefreshLimit     = 500
+	// refreshConcurrency bounds the synchronous upstream calls of a dry run.
+	refreshConcurrency = 4
+)
+
+// refresher re-enriches songs whose details are stale; it is shared by every handler.
+var refresher *songRefresher
+
+// songRefresher periodically queues stale songs for enrichment. A song is stale when its last
+// enrichment failed, it has no lyrics, or it hasn't been enriched for staleAfter; in any case it is
+// attempted at most once per staleAfter.
+type songRefresher struct {
+	interval   time.Duration
+	staleAfter time.Duration
+	batchSize  int
+}
+
+type refreshTarget struct {
+	SongID    int    `db:"id_song"`
+	GroupName string `db:"group"`
+	SongName  string `db:"song"`
+}
+
+func newSongRefresherFromEnv() *songRefresher {
+	r := &songRefresher{
+		interval:   envDuration("REFRESH_INTERVAL", time.Hour),
+		staleAfter: envDuration("REFRESH_STALE_AFTER", 30*24*time.Hour),
+		batchSize:  envInt("REFRESH_BATCH_SIZE", 50),
+	}
+	slog.Info("Song refresh configured", "interval", r.interval, "stale_after", r.staleAfter, "batch_size", r.batchSize)
+	return r
+}
+
+// Start runs the scheduled refresh until ctx is cancelled; a non-positive interval disables it.
+func (r *songRefresher) Start(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			targets, err := r.staleSongs(r.batchSize)
+			if err != nil {
+				slog.Error("Failed to select stale songs", "error", err)
+				continue
+			}
+			if err := enqueueRefresh(targets); err != nil {
+				slog.Error("Failed to queue stale songs", "error", err)
+				continue
+			}
+			if len(targets) > 0 {
+				slog.Info("Queued stale songs for refresh", "songs", len(targets))
+			}
+		}
+	}()
+}
+
+// staleSongs returns up to limit stale songs, least recently attempted first. Songs already waiting
+// for enrichment are skipped, and so are songs attempted within staleAfter, so failures that keep
+// failing don't take every batch from songs that were enriched long ago.
+func (r *songRefresher) staleSongs(limit int) ([]refreshTarget, error) {
+	var targets []refreshTarget
+	query := `
+		SELECT s.id_song, g.groupName AS group, s.song
+		FROM songs s
+		JOIN musicGroups g ON g.id_group = s.id_group
+		WHERE s.enrichment_status <> 'pending'
+			AND (s.enrichment_status IN ('failed', 'not_found') OR s.lyrics = ''
+				OR s.enriched_at IS NULL OR s.enriched_at < now() - $1 * interval '1 millisecond')
+			AND (s.enrichment_attempted_at IS NULL OR s.enrichment_attempted_at < now() - $1 * interval '1 millisecond')
+		ORDER BY s.enrichment_attempted_at NULLS FIRST, s.id_song
+		LIMIT $2`
+	err := db.Select(&targets, query, r.staleAfter.Milliseconds(), limit)
+	return targets, err
+}
+
+func enqueueRefresh(targets []refreshTarget) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, target := range targets {
+		if err := queue.Enqueue(tx, target.SongID, true); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	queue.Notify()
+	return nil
+}
+
+// @Summary Refresh song details
+// @Description Re-fetch details from the enrichment providers for one song, a group, or stale songs (failed, without lyrics or not enriched recently, and not attempted recently). Fetched values replace stored ones; fields the providers don't return are kept. With dry_run nothing is stored and the response lists what would change. A single song is refreshed immediately, groups and stale songs are queued.
+// @Tags enrichment
+// @Accept  json
+// @Produce  json
+// @Param id_song query int false "ID of the song to refresh"
+// @Param id_group query int false "ID of the group whose songs to refresh"
+// @Param stale query bool false "Refresh stale songs"
+// @Param dry_run query bool false "Only show what would change"
+// @Param limit query int false "Maximum number of songs (default 50, at most 500)"
+// @Success 200 {object} RefreshReport "Refreshed, queued or previewed songs"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song or group not found"
+// @Failure 500 {string} string "Failed to refresh songs"
+// @Router /songs/refresh [post]
+func refreshSongs(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request refreshSongs")
+
+	query := r.URL.Query()
+	idSongStr, idGroupStr, staleStr := query.Get("id_song"), query.Get("id_group"), query.Get("stale")
+
+	selectors := 0
+	for _, value := range []string{idSongStr, idGroupStr, staleStr} {
+		if value != "" {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		slog.Warn("Refresh needs exactly one selector", "id_song", idSongStr, "id_group", idGroupStr, "stale", staleStr)
+		http.Error(w, "Exactly one of 'id_song', 'id_group' or 'stale' is required", http.StatusBadRequest)
+		return
+	}
+
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			slog.Warn("Invalid dry_run parameter", "dry_run", value)
+			http.Error(w, "Invalid 'dry_run' parameter", http.StatusBadRequest)
+			return
+		}
+		dryRun = parsed
+	}
+
+	limit := defaultRefreshLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			slog.Warn("Invalid limit parameter", "limit", value)
+			http.Error(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxRefreshLimit)
+	}
+
+	var targets []refreshTarget
+	var err error
+	switch {
+	case idSongStr != "":
+		idSong, convErr := strconv.Atoi(idSongStr)
+		if convErr != nil || idSong < 1 {
+			slog.Warn("Invalid id_song parameter", "id_song", idSongStr)
+			http.Error(w, "Invalid 'id_song' parameter", http.StatusBadRequest)
+			return
+		}
+		err = db.Select(&targets, `
+			SELECT s.id_song, g.groupName AS group, s.song
+			FROM songs s
+			JOIN musicGroups g ON g.id_group = s.id_group
+			WHERE s.id_song = $1`, idSong)
+		if err == nil && len(targets) == 0 {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+	case idGroupStr != "":
+		idGroup, convErr := strconv.Atoi(idGroupStr)
+		if convErr != nil || idGroup < 1 {
+			slog.Warn("Invalid id_group parameter", "id_group", idGroupStr)
+			http.Error(w, "Invalid 'id_group' parameter", http.StatusBadRequest)
+			return
+		}
+		var exists bool
+		err = db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM musicGroups WHERE id_group = $1)", idGroup)
+		if err == nil && !exists {
+			slog.Warn("Group not found", "id_group", idGroup)
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		if err == nil {
+			err = db.Select(&targets, `
+				SELECT s.id_song, g.groupName AS group, s.song
+				FROM songs s
+				JOIN musicGroups g ON g.id_group = s.id_group
+				WHERE s.id_group = $1
+				ORDER BY s.id_song
+				LIMIT $2`, idGroup, limit)
+		}
+	default:
+		stale, convErr := strconv.ParseBool(staleStr)
+		if convErr != nil || !stale {
+			slog.Warn("Invalid stale parameter", "stale", staleStr)
+			http.Error(w, "Invalid 'stale' parameter", http.StatusBadRequest)
+			return
+		}
+		targets, err = refresher.staleSongs(limit)
+	}
+	if err != nil {
+		slog.Error("Failed to select songs to refresh", "error", err)
+		http.Error(w, "Failed to refresh songs", http.StatusInternalServerError)
+		return
+	}
+
+	report := RefreshReport{DryRun: dryRun, Songs: []RefreshResult{}}
+	switch {
+	case dryRun:
+		report.Songs = previewRefresh(r.Context(), targets)
+	case idSongStr != "":
+		result, err := refreshSong(r.Context(), targets[0])
+		if err != nil {
+			slog.Error("Failed to refresh song", "id_song", targets[0].SongID, "error", err)
+			http.Error(w, "Failed to refresh songs", http.StatusInternalServerError)
+			return
+		}
+		report.Songs = append(report.Songs, result)
+	default:
+		if err := enqueueRefresh(targets); err != nil {
+			slog.Error("Failed to queue songs for refresh", "error", err)
+			http.Error(w, "Failed to refresh songs", http.StatusInternalServerError)
+			return
+		}
+		for _, target := range targets {
+			report.Songs = append(report.Songs, RefreshResult{
+				SongID: target.SongID, GroupName: target.GroupName, SongName: target.SongName, Status: refreshQueued,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+
+	slog.Debug("Songs refreshed successfully", "songs", len(report.Songs), "dry_run", dryRun)
+}
+
+// previewRefresh fetches details for every target and reports what storing them would change.
+func previewRefresh(ctx context.Context, targets []refreshTarget) []RefreshResult {
+	results := make([]RefreshResult, len(targets))
+	sem := make(chan struct{}, refreshConcurrency)
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target refreshTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := RefreshResult{SongID: target.SongID, GroupName: target.GroupName, SongName: target.SongName}
+			detail, err := enricher.Fetch(bypassCache(ctx), target.GroupName, target.SongName)
+			if hasDetails(err) {
+				var changesErr error
+				if result.Changes, changesErr = songDetailChanges(target.SongID, detail); changesErr != nil {
+					err = changesErr
+				}
+			}
+			result.Status, result.Error = refreshOutcome(result.Changes, err)
+			results[i] = result
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+// refreshSong fetches and stores details of one song. Upstream failures are recorded in the
+// enrichment status like a queued job would; only database errors are returned.
+func refreshSong(ctx context.Context, target refreshTarget) (RefreshResult, error) {
+	result := RefreshResult{SongID: target.SongID, GroupName: target.GroupName, SongName: target.SongName}
+
+	detail, fetchErr := enricher.Fetch(bypassCache(ctx), target.GroupName, target.SongName)
+	if hasDetails(fetchErr) {
+		changes, err := songDetailChanges(target.SongID, detail)
+		if err != nil {
+			return result, err
+		}
+		result.Changes = changes
+	}
+	result.Status, result.Error = refreshOutcome(result.Changes, fetchErr)
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	switch {
+	case fetchErr == nil:
+		err = storeSongDetail(tx, target.SongID, detail)
+	case hasDetails(fetchErr):
+		// The providers that failed for now are asked again by the queue.
+		if err = storeSongDetail(tx, target.SongID, detail); err == nil {
+			err = queue.Enqueue(tx, target.SongID, true)
+		}
+	case errors.Is(fetchErr, errSongDetailsNotFound):
+		err = setEnrichmentStatus(tx, target.SongID, enrichmentNotFound, fetchErr)
+	default:
+		err = setEnrichmentStatus(tx, target.SongID, enrichmentFailed, fetchErr)
+	}
+	if err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	if fetchErr != nil && hasDetails(fetchErr) {
+		queue.Notify()
+	}
+
+	slog.Info("Song refreshed", "id_song", target.SongID, "status", result.Status)
+	return result, nil
+}
+
+// refreshOutcome gives the status of a refreshed song and its error. A partly failed fetch still
+// reports its changes, with the failure as the error.
+func refreshOutcome(changes []FieldChange, err error) (string, string) {
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	switch {
+	case hasDetails(err) && len(changes) > 0:
+		return refreshChanged, message
+	case hasDetails(err):
+		return refreshUnchanged, message
+	case errors.Is(err, errSongDetailsNotFound):
+		return enrichmentNotFound, message
+	default:
+		return enrichmentFailed, message
+	}
+}
+
+// songDetailChanges compares fetched details with the stored ones, field by field, the way
+// storeSongDetail would apply them: only fields the providers returned can change.
+func songDetailChanges(idSong int, detail SongDetail) ([]FieldChange, error) {
+	sanitizeSongDetail(idSong, &detail)
+
+	var stored SongDetail
+	query := `
+		SELECT ` + releaseDateColumnsSQL + `, s.lyrics, ` + primaryLinkSQL + `, s.detail_sources, ` + audioMetadataColumnsSQL + `
+		FROM songs s
+		WHERE s.id_song = $1`
+	if err := db.Get(&stored, query, idSong); err != nil {
+		if err == sql.ErrNoRows {
+			// Deleted meanwhile; nothing to change.
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	links, err := songLinks([]int64{int64(idSong)})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	for _, field := range detailFields {
+		if !field.isSet(&detail) {
+			continue
+		}
+		if field.name == "link" {
+			// Fetched links are added next to existing ones rather than replacing the primary link.
+			known := false
+			for _, link := range links[idSong] {
+				known = known || link.URL == detail.Link
+			}
+			if !known {
+				changes = append(changes, FieldChange{Field: field.name, Old: nil, New: detail.Link})
+			}
+			continue
+		}
+		var old interface{}
+		if field.isSet(&stored) {
+			old = field.value(&stored)
+		}
+		fetched := field.value(&detail)
+		if old != fetched {
+			changes = append(changes, FieldChange{Field: field.name, Old: old, New: fetched})
+		}
+	}
+	return changes, nil
+}
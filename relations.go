@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+var relationTypes = map[string]bool{
+	"cover":       true,
+	"remix":       true,
+	"live":        true,
+	"translation": true,
+}
+
+var errRelationCycle = errors.New("relation would create a cycle")
+
+// pathID reads a numeric path variable, writing a 400 response if it is not a positive integer.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	idStr := mux.Vars(r)[name]
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 {
+		slog.Warn("Invalid '"+name+"' parameter", name, idStr)
+		http.Error(w, "Invalid '"+name+"' parameter", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func songIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	return pathID(w, r, "id_song")
+}
+
+// @Summary Link a song to its original
+// @Description Record that a song is a cover, remix, live version or translation of another song.
+// @Tags relations
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the derived song"
+// @Param input body SongRelation true "Original song ID and relation type (cover, remix, live, translation)"
+// @Success 201 {object} SongRelation "The created relation"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Song not found"
+// @Failure 409 {string} string "Relation already exists or would create a cycle"
+// @Failure 500 {string} string "Failed to link songs"
+// @Router /songs/{id_song}/relations [post]
+func linkSong(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to linkSong")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var relation SongRelation
+	if err := json.NewDecoder(r.Body).Decode(&relation); err != nil {
+		slog.Warn("Invalid JSON format", "error", err)
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	relation.SongID = idSong
+
+	if !relationTypes[relation.RelationType] {
+		slog.Warn("Invalid relation type", "type", relation.RelationType)
+		http.Error(w, "Relation type must be one of: cover, remix, live, translation", http.StatusBadRequest)
+		return
+	}
+	if relation.OriginalID < 1 || relation.OriginalID == idSong {
+		slog.Warn("Invalid 'id_original' value", "id_original", relation.OriginalID)
+		http.Error(w, "Invalid 'id_original' value", http.StatusBadRequest)
+		return
+	}
+
+	err := insertRelation(&relation)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, errRelationCycle):
+			slog.Warn("Relation would create a cycle", "id_song", idSong, "id_original", relation.OriginalID)
+			http.Error(w, "Relation would create a cycle", http.StatusConflict)
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			slog.Warn("Song not found", "id_song", idSong, "id_original", relation.OriginalID)
+			http.Error(w, "Song not found", http.StatusNotFound)
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			slog.Warn("Relation already exists", "id_song", idSong, "id_original", relation.OriginalID)
+			http.Error(w, "Relation already exists", http.StatusConflict)
+		default:
+			slog.Error("Failed to link songs", "error", err)
+			http.Error(w, "Failed to link songs", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(relation)
+
+	slog.Debug("Songs linked successfully", "id_song", idSong, "id_original", relation.OriginalID, "type", relation.RelationType)
+}
+
+// insertRelation stores the relation unless the original already derives, directly or transitively, from the song.
+func insertRelation(relation *SongRelation) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serialize writers so two concurrent links can't close a cycle between them.
+	if _, err := tx.Exec("LOCK TABLE song_relations IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	var cycle bool
+	query := `
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT id_original FROM song_relations WHERE id_song = $1
+			UNION
+			SELECT r.id_original FROM song_relations r JOIN ancestors a ON r.id_song = a.id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`
+	if err := tx.Get(&cycle, query, relation.OriginalID, relation.SongID); err != nil {
+		return err
+	}
+	if cycle {
+		return errRelationCycle
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO song_relations (id_song, id_original, relation_type) VALUES ($1, $2, $3) RETURNING id_relation",
+		relation.SongID, relation.OriginalID, relation.RelationType,
+	).Scan(&relation.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// @Summary Unlink a song from its original
+// @Description Remove the relation between a derived song and its original. Without 'type' all relations between the two songs are removed.
+// @Tags relations
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the derived song"
+// @Param id_original query int true "ID of the original song"
+// @Param type query string false "Relation type (cover, remix, live, translation)"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Relation not found"
+// @Failure 500 {string} string "Failed to unlink songs"
+// @Router /songs/{id_song}/relations [delete]
+func unlinkSong(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request to unlinkSong")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	originalStr := r.URL.Query().Get("id_original")
+	idOriginal, err := strconv.Atoi(originalStr)
+	if err != nil || idOriginal < 1 {
+		slog.Warn("Invalid 'id_original' parameter", "id_original", originalStr)
+		http.Error(w, "Invalid 'id_original' parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := `DELETE FROM song_relations WHERE id_song = $1 AND id_original = $2`
+	args := []interface{}{idSong, idOriginal}
+
+	relationType := r.URL.Query().Get("type")
+	if relationType != "" {
+		if !relationTypes[relationType] {
+			slog.Warn("Invalid relation type", "type", relationType)
+			http.Error(w, "Relation type must be one of: cover, remix, live, translation", http.StatusBadRequest)
+			return
+		}
+		query += " AND relation_type = $3"
+		args = append(args, relationType)
+	}
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		slog.Error("Failed to unlink songs", "error", err)
+		http.Error(w, "Failed to unlink songs", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		slog.Warn("Relation not found", "id_song", idSong, "id_original", idOriginal)
+		http.Error(w, "Relation not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Debug("Songs unlinked successfully", "id_song", idSong, "id_original", idOriginal)
+}
+
+// @Summary Song versions
+// @Description Get the whole family tree of a song: its originals up to the root and every cover, remix, live version and translation below them.
+// @Tags relations
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Success 200 {array} SongVersion "Root songs of the family with nested versions"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch song versions"
+// @Router /songs/{id_song}/versions [get]
+func getSongVersions(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getSongVersions")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var exists bool
+	if err := db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM songs WHERE id_song = $1)", idSong); err != nil {
+		slog.Error("Failed to fetch song versions", "error", err)
+		http.Error(w, "Failed to fetch song versions", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		slog.Warn("Song not found", "id_song", idSong)
+		http.Error(w, "Song not found", http.StatusNotFound)
+		return
+	}
+
+	roots, err := fetchSongFamily(idSong)
+	if err != nil {
+		slog.Error("Failed to fetch song versions", "error", err)
+		http.Error(w, "Failed to fetch song versions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roots)
+
+	slog.Debug("Song versions fetched successfully", "id_song", idSong, "roots", len(roots))
+}
+
+// fetchSongFamily walks up from the song to every root original, then down from the roots to every derived version.
+func fetchSongFamily(idSong int) ([]SongVersion, error) {
+	var rootIDs []int64
+	query := `
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT $1::int
+			UNION
+			SELECT r.id_original FROM song_relations r JOIN ancestors a ON r.id_song = a.id
+		)
+		SELECT id FROM ancestors a
+		WHERE NOT EXISTS (SELECT 1 FROM song_relations r WHERE r.id_song = a.id)
+		ORDER BY id`
+	if err := db.Select(&rootIDs, query, idSong); err != nil {
+		return nil, err
+	}
+
+	var nodes []SongVersion
+	query = `
+		WITH RECURSIVE family(id) AS (
+			SELECT unnest($1::int[])
+			UNION
+			SELECT r.id_song FROM song_relations r JOIN family f ON r.id_original = f.id
+		)
+		SELECT s.id_song, g.groupName AS group, s.song
+		FROM songs s
+		JOIN musicGroups g ON s.id_group = g.id_group
+		WHERE s.id_song IN (SELECT id FROM family)`
+	if err := db.Select(&nodes, query, pq.Array(rootIDs)); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(nodes))
+	songs := make(map[int]SongVersion, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, int64(node.ID))
+		songs[node.ID] = node
+	}
+
+	var edges []SongRelation
+	query = `
+		SELECT id_relation, id_song, id_original, relation_type
+		FROM song_relations
+		WHERE id_original = ANY($1)
+		ORDER BY id_song, relation_type`
+	if err := db.Select(&edges, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]SongRelation)
+	for _, edge := range edges {
+		children[edge.OriginalID] = append(children[edge.OriginalID], edge)
+	}
+
+	var build func(id int, relationType string, path map[int]bool) SongVersion
+	build = func(id int, relationType string, path map[int]bool) SongVersion {
+		version := songs[id]
+		version.RelationType = relationType
+		path[id] = true
+		for _, edge := range children[id] {
+			if path[edge.SongID] {
+				continue
+			}
+			version.Versions = append(version.Versions, build(edge.SongID, edge.RelationType, path))
+		}
+		delete(path, id)
+		return version
+	}
+
+	roots := make([]SongVersion, 0, len(rootIDs))
+	for _, id := range rootIDs {
+		roots = append(roots, build(int(id), "", map[int]bool{}))
+	}
+	return roots, nil
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Where a lyrics revision came from.
+const (
+	revisionImport     = "import"
+	revisionEdit       = "edit"
+	revisionEnrichment = "enrichment"
+	revisionRollback   = "rollback"
+)
+
+// Operations of a line in a diff.
+const (
+	diffEqual  = "equal"
+	diffInsert = "insert"
+	diffDelete = "delete"
+)
+
+const (
+	// maxAuthorLength bounds the X-Author header stored with a revision.
+	maxAuthorLength = 255
+	// maxDiffCells bounds the line-by-line table of a diff; longer lyrics are diffed as one replacement.
+	maxDiffCells = 4_000_000
+)
+
+// revisionAuthor names who made a change: the X-Author header, or "anonymous" without one.
+func revisionAuthor(r *http.Request) string {
+	author := strings.TrimSpace(r.Header.Get("X-Author"))
+	if author == "" {
+		return "anonymous"
+	}
+	if len(author) > maxAuthorLength {
+		author = author[:maxAuthorLength]
+		for !utf8.ValidString(author) {
+			author = author[:len(author)-1]
+		}
+	}
+	return author
+}
+
+// recordLyricsRevision stores the current lyrics of a song as a new revision unless they equal the
+// latest one, and moves the song's annotations along with the change. Callers update songs first in
+// the same transaction, so the row lock orders revisions. restoredFrom is the revision a rollback
+// restored, or 0. It returns the latest revision number and whether it was just created.
+func recordLyricsRevision(tx *sqlx.Tx, idSong int, author, source string, restoredFrom int) (int, bool, error) {
+	var current struct {
+		Lyrics   string         `db:"lyrics"`
+		Revision sql.NullInt64  `db:"revision"`
+		Latest   sql.NullString `db:"latest"`
+	}
+	query := `
+		SELECT COALESCE(s.lyrics, '') AS lyrics, r.revision, r.lyrics AS latest
+		FROM songs s
+		LEFT JOIN LATERAL (
+			SELECT revision, lyrics FROM lyrics_revisions WHERE id_song = s.id_song ORDER BY revision DESC LIMIT 1
+		) r ON true
+		WHERE s.id_song = $1`
+	if err := tx.Get(&current, query, idSong); err != nil {
+		return 0, false, err
+	}
+	if current.Latest.Valid && current.Latest.String == current.Lyrics {
+		return int(current.Revision.Int64), false, nil
+	}
+	// A song that never had lyrics needs no empty first revision.
+	if !current.Revision.Valid && current.Lyrics == "" {
+		return 0, false, nil
+	}
+
+	revision := int(current.Revision.Int64) + 1
+	_, err := tx.Exec(`
+		INSERT INTO lyrics_revisions (id_song, revision, lyrics, author, source, restored_from)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))`,
+		idSong, revision, current.Lyrics, author, source, restoredFrom)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := reanchorAnnotations(tx, idSong, current.Latest.String, current.Lyrics); err != nil {
+		return 0, false, err
+	}
+	return revision, true, nil
+}
+
+const revisionColumnsSQL = `id_song, revision, lyrics, author, source, restored_from, created_at`
+
+func fetchLyricsRevision(idSong, revision int) (LyricsRevision, error) {
+	var rev LyricsRevision
+	err := db.Get(&rev, "SELECT "+revisionColumnsSQL+" FROM lyrics_revisions WHERE id_song = $1 AND revision = $2", idSong, revision)
+	return rev, err
+}
+
+// diffLines compares two texts line by line and returns the edit script turning a into b.
+func diffLines(a, b []string) []DiffLine {
+	diff := []DiffLine{}
+
+	// Common prefix and suffix need no table, which keeps small edits to long lyrics cheap.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for i := 0; i < prefix; i++ {
+		diff = append(diff, DiffLine{Op: diffEqual, Text: a[i], OldLine: i + 1, NewLine: i + 1})
+	}
+
+	oldMid, newMid := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(oldMid), len(newMid)
+	if n*m > maxDiffCells {
+		for i, line := range oldMid {
+			diff = append(diff, DiffLine{Op: diffDelete, Text: line, OldLine: prefix + i + 1})
+		}
+		for j, line := range newMid {
+			diff = append(diff, DiffLine{Op: diffInsert, Text: line, NewLine: prefix + j + 1})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of oldMid[i:] and newMid[j:].
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if oldMid[i] == newMid[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && oldMid[i] == newMid[j]:
+				diff = append(diff, DiffLine{Op: diffEqual, Text: oldMid[i], OldLine: prefix + i + 1, NewLine: prefix + j + 1})
+				i++
+				j++
+			// Deletions come before insertions, as in unified diffs.
+			case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+				diff = append(diff, DiffLine{Op: diffDelete, Text: oldMid[i], OldLine: prefix + i + 1})
+				i++
+			default:
+				diff = append(diff, DiffLine{Op: diffInsert, Text: newMid[j], NewLine: prefix + j + 1})
+				j++
+			}
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		i, j := len(a)-suffix+k, len(b)-suffix+k
+		diff = append(diff, DiffLine{Op: diffEqual, Text: a[i], OldLine: i + 1, NewLine: j + 1})
+	}
+	return diff
+}
+
+// textLines splits lyrics into lines; empty lyrics have none.
+func textLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+// @Summary Lyrics revision history
+// @Description List every stored revision of a song's lyrics, newest first, with author, source (import, edit, enrichment or rollback) and time. The text is left out; fetch a single revision for it.
+// @Tags lyrics
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Success 200 {array} LyricsRevision "Revisions of the lyrics"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch revisions"
+// @Router /songs/{id_song}/lyrics/revisions [get]
+func getLyricsRevisions(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getLyricsRevisions")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var exists bool
+	if err := db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM songs WHERE id_song = $1)", idSong); err != nil {
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to fetch revisions", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		slog.Warn("Song not found", "id_song", idSong)
+		http.Error(w, "Song not found", http.StatusNotFound)
+		return
+	}
+
+	revisions := []LyricsRevision{}
+	query := "SELECT " + revisionColumnsSQL + " FROM lyrics_revisions WHERE id_song = $1 ORDER BY revision DESC"
+	if err := db.Select(&revisions, query, idSong); err != nil {
+		slog.Error("Failed to fetch revisions", "error", err)
+		http.Error(w, "Failed to fetch revisions", http.StatusInternalServerError)
+		return
+	}
+	for i := range revisions {
+		revisions[i].Lines = len(textLines(revisions[i].Lyrics))
+		revisions[i].Lyrics = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// revisionFromPath reads the {revision} path variable, writing a 400 response if it is invalid.
+func revisionFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	return pathID(w, r, "revision")
+}
+
+// @Summary Get a lyrics revision
+// @Description Get one revision of a song's lyrics with its text.
+// @Tags lyrics
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param revision path int true "Revision number"
+// @Success 200 {object} LyricsRevision "The revision"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Revision not found"
+// @Failure 500 {string} string "Failed to fetch revision"
+// @Router /songs/{id_song}/lyrics/revisions/{revision} [get]
+func getLyricsRevision(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getLyricsRevision")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := revisionFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	rev, err := fetchLyricsRevision(idSong, revision)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Revision not found", "id_song", idSong, "revision", revision)
+			http.Error(w, "Revision not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch revision", "error", err)
+		http.Error(w, "Failed to fetch revision", http.StatusInternalServerError)
+		return
+	}
+	rev.Lines = len(textLines(rev.Lyrics))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev)
+}
+
+// @Summary Diff two lyrics revisions
+// @Description Compare two revisions of a song's lyrics line by line. 'to' defaults to the latest revision and 'from' to the one before 'to'. Each line of the result is equal, insert or delete, with its line numbers in the old and new text.
+// @Tags lyrics
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param from query int false "Older revision (default is the one before 'to')"
+// @Param to query int false "Newer revision (default is the latest)"
+// @Success 200 {object} LyricsDiff "Line-level diff"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Revision not found"
+// @Failure 500 {string} string "Failed to diff revisions"
+// @Router /songs/{id_song}/lyrics/diff [get]
+func getLyricsDiff(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getLyricsDiff")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var bounds [2]int
+	for i, name := range []string{"from", "to"} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			slog.Warn("Invalid revision parameter", name, value)
+			http.Error(w, "Invalid '"+name+"' parameter", http.StatusBadRequest)
+			return
+		}
+		bounds[i] = number
+	}
+	from, to := bounds[0], bounds[1]
+
+	if to == 0 {
+		if err := db.Get(&to, "SELECT COALESCE(MAX(revision), 0) FROM lyrics_revisions WHERE id_song = $1", idSong); err != nil {
+			slog.Error("Failed to fetch latest revision", "error", err)
+			http.Error(w, "Failed to diff revisions", http.StatusInternalServerError)
+			return
+		}
+		if to == 0 {
+			slog.Warn("Song has no lyrics revisions", "id_song", idSong)
+			http.Error(w, "Revision not found", http.StatusNotFound)
+			return
+		}
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+
+	revs := make([]LyricsRevision, 2)
+	for i, revision := range []int{from, to} {
+		rev, err := fetchLyricsRevision(idSong, revision)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				slog.Warn("Revision not found", "id_song", idSong, "revision", revision)
+				http.Error(w, "Revision "+strconv.Itoa(revision)+" not found", http.StatusNotFound)
+				return
+			}
+			slog.Error("Failed to fetch revision", "error", err)
+			http.Error(w, "Failed to diff revisions", http.StatusInternalServerError)
+			return
+		}
+		revs[i] = rev
+	}
+
+	result := LyricsDiff{SongID: idSong, From: from, To: to, Lines: diffLines(textLines(revs[0].Lyrics), textLines(revs[1].Lyrics))}
+	for _, line := range result.Lines {
+		switch line.Op {
+		case diffInsert:
+			result.Added++
+		case diffDelete:
+			result.Removed++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// @Summary Roll lyrics back to a revision
+// @Description Restore the lyrics of an old revision. The restore is itself recorded as a new revision, so history is never rewritten; when the lyrics already match, nothing changes and the latest revision is returned with 200.
+// @Tags lyrics
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param revision path int true "Revision to restore"
+// @Param X-Author header string false "Who makes the change (default anonymous)"
+// @Success 200 {object} LyricsRevision "Lyrics already matched the revision"
+// @Success 201 {object} LyricsRevision "The new revision"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Revision not found"
+// @Failure 500 {string} string "Failed to roll back lyrics"
+// @Router /songs/{id_song}/lyrics/revisions/{revision}/rollback [post]
+func rollbackLyrics(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request rollbackLyrics")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := revisionFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	target, err := fetchLyricsRevision(idSong, revision)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Revision not found", "id_song", idSong, "revision", revision)
+			http.Error(w, "Revision not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch revision", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE songs SET lyrics = $1 WHERE id_song = $2", target.Lyrics, idSong); err != nil {
+		slog.Error("Failed to restore lyrics", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+	if err := syncLyricSections(tx, idSong); err != nil {
+		slog.Error("Failed to update lyric sections", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+	if err := updateLyricsLanguage(tx, idSong); err != nil {
+		slog.Error("Failed to detect lyrics language", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+	if err := updateExplicitFlag(tx, idSong); err != nil {
+		slog.Error("Failed to check lyrics for profanity", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+	latest, created, err := recordLyricsRevision(tx, idSong, revisionAuthor(r), revisionRollback, revision)
+	if err != nil {
+		slog.Error("Failed to record lyrics revision", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Failed to commit rollback", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+
+	rev, err := fetchLyricsRevision(idSong, latest)
+	if err != nil {
+		slog.Error("Failed to fetch revision", "error", err)
+		http.Error(w, "Failed to roll back lyrics", http.StatusInternalServerError)
+		return
+	}
+	rev.Lines = len(textLines(rev.Lyrics))
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(rev)
+
+	slog.Info("Lyrics rolled back", "id_song", idSong, "restored", revision, "revision", latest, "created", created)
+}
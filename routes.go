@@ -3,18 +3,23 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// songColumnsSQL selects every Song field from songs s joined with musicGroups g.
+const songColumnsSQL = `s.id_song, s.id_group, g.groupName AS group, s.song, ` + releaseDateColumnsSQL + `,
+        s.lyrics, ` + primaryLinkSQL + `, s.isrc, s.iswc, s.enrichment_status, s.detail_sources, s.language,
+        s.language_confidence, s.language_source, ` + audioMetadataColumnsSQL
+
 func setupRoutes() *mux.Router {
 	slog.Info("Initializing router")
 	r := mux.NewRouter()
@@ -26,6 +31,52 @@ func setupRoutes() *mux.Router {
 	r.HandleFunc("/songs", deleteSong).Methods("DELETE")
 	r.HandleFunc("/songs/text", getSongText).Methods("GET")
 	r.HandleFunc("/songs", getSongsFiltered).Methods("GET")
+	r.HandleFunc("/songs/facets/language", getLanguageFacets).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/relations", linkSong).Methods("POST")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/relations", unlinkSong).Methods("DELETE")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/versions", getSongVersions).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/enrichment", getEnrichmentStatus).Methods("GET")
+	r.HandleFunc("/songs/refresh", refreshSongs).Methods("POST")
+	r.HandleFunc("/enrichment/cache", getCacheStats).Methods("GET")
+	r.HandleFunc("/enrichment/limits", getLimiterStats).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/lyrics/revisions", getLyricsRevisions).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/lyrics/revisions/{revision:[0-9]+}", getLyricsRevision).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/lyrics/revisions/{revision:[0-9]+}/rollback", rollbackLyrics).Methods("POST")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/lyrics/diff", getLyricsDiff).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/lyrics/annotated", getAnnotatedLyrics).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/annotations", getAnnotations).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/annotations", addAnnotation).Methods("POST")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/annotations/{id_annotation:[0-9]+}", getAnnotation).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/annotations/{id_annotation:[0-9]+}", updateAnnotation).Methods("PUT")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/annotations/{id_annotation:[0-9]+}", deleteAnnotation).Methods("DELETE")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/translations", getSongTranslations).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/translations/{language}", getSongTranslation).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/translations/{language}", putSongTranslation).Methods("PUT")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/translations/{language}", deleteSongTranslation).Methods("DELETE")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/analysis", getLyricsAnalysis).Methods("GET")
+	r.HandleFunc("/groups/{id_group:[0-9]+}/analysis", getGroupAnalysis).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/chords", putChordSheet).Methods("PUT")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/chords", getChordSheet).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/chords", deleteChordSheet).Methods("DELETE")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/lrc", putSyncedLyrics).Methods("PUT")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/lrc", getSyncedLyrics).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/lrc", deleteSyncedLyrics).Methods("DELETE")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/karaoke", getKaraokeFrame).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/links", getSongLinks).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/links", addSongLink).Methods("POST")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/links/{id_link:[0-9]+}", deleteSongLink).Methods("DELETE")
+	r.HandleFunc("/songs/by-isrc/{isrc}", getSongByISRC).Methods("GET")
+	r.HandleFunc("/songs/by-iswc/{iswc}", getSongsByISWC).Methods("GET")
+	r.HandleFunc("/songs/by-external-id", getSongByExternalID).Methods("GET")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/external-ids/{provider}", setSongExternalID).Methods("PUT")
+	r.HandleFunc("/songs/{id_song:[0-9]+}/external-ids/{provider}", deleteSongExternalID).Methods("DELETE")
+	r.HandleFunc("/profanity/terms", getProfanityTerms).Methods("GET")
+	r.HandleFunc("/profanity/terms", addProfanityTerm).Methods("POST")
+	r.HandleFunc("/profanity/terms/{id_term:[0-9]+}", deleteProfanityTerm).Methods("DELETE")
+	r.HandleFunc("/profanity/rescan", rescanProfanity).Methods("POST")
+	r.HandleFunc("/groups/by-external-id", getGroupByExternalID).Methods("GET")
+	r.HandleFunc("/groups/{id_group:[0-9]+}/external-ids/{provider}", setGroupExternalID).Methods("PUT")
+	r.HandleFunc("/groups/{id_group:[0-9]+}/external-ids/{provider}", deleteGroupExternalID).Methods("DELETE")
 
 	r.PathPrefix("/OnlineMusicLibrary/docs/").Handler(http.StripPrefix("/OnlineMusicLibrary/docs/", http.FileServer(http.Dir("docs/"))))
 	r.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
@@ -39,26 +90,44 @@ func setupRoutes() *mux.Router {
 }
 
 // @Summary Add a new song
-// @Description Add a new song by providing group name and song name. Details (release date, text, link) are fetched from an external API.
+// @Description Add a new song by providing group name and song name. Details (release date, text, link) are fetched from the enrichment providers according to the policy: strict fetches them first and rejects the song on failure, lenient fetches them first and stores the song with empty details on failure, deferred stores the song with enrichment_status "pending" and fetches details in the background (poll GET /songs/{id_song}/enrichment).
 // @Tags songs
 // @Accept  json
 // @Produce  json
 // @Param input body SongShort true "Group and Song names"
-// @Success 201 {string} string "Song added successfully"
+// @Param policy query string false "Enrichment policy: strict, lenient or deferred (default is ENRICHMENT_POLICY)"
+// @Param dry_run query bool false "Only preview the song: details are fetched whatever the policy, nothing is stored"
+// @Success 201 {object} Song "The added song"
+// @Success 200 {object} SongPreview "Preview of the song (dry_run)"
 // @Failure 400 {string} string "Invalid input"
-// @Failure 404 {string} string "Song details not found"
+// @Failure 404 {string} string "Song details not found (strict policy)"
 // @Failure 500 {string} string "Failed to add song"
+// @Failure 502 {string} string "Failed to fetch song details (strict policy)"
 // @Router /songs [post]
 func addSong(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Received request to addSong")
 
-	apiURL := os.Getenv("EXTERNAL_API_URL")
-	if apiURL == "" {
-		slog.Error("External API URL not configured")
-		//http.Error(w, "External API URL not configured", http.StatusInternalServerError)
-		//return
+	policy := defaultEnrichmentPolicy
+	if policyStr := r.URL.Query().Get("policy"); policyStr != "" {
+		var err error
+		policy, err = parseEnrichmentPolicy(policyStr)
+		if err != nil {
+			slog.Warn("Invalid policy parameter", "policy", policyStr)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			slog.Warn("Invalid dry_run parameter", "dry_run", value)
+			http.Error(w, "Invalid 'dry_run' parameter", http.StatusBadRequest)
+			return
+		}
 	}
-	slog.Debug("External API URL:", "url", apiURL)
 
 	var input SongShort
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -74,38 +143,32 @@ func addSong(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var songDetail SongDetail
-	songDetail.ReleaseDate = "2024-11-25"
-	songDetail.Lyrics = "Text Placeholder Verse1 \n\nText Placeholder Verse2\n\nText Placeholder Verse3"
-	songDetail.Link = "Link Placeholder"
-
-	fullURL := fmt.Sprintf("%s?group=%s&song=%s",
-		apiURL,
-		url.QueryEscape(input.GroupName),
-		url.QueryEscape(input.SongName),
-	)
-
-	resp, err := http.Get(fullURL)
-	if err != nil {
-		slog.Warn("Failed to call external API, using placeholders", "error", err)
-	} else if resp.StatusCode != http.StatusOK {
-		slog.Warn("External API returned non-200 status", "status", resp.StatusCode)
-		if resp.StatusCode == http.StatusNotFound {
-			http.Error(w, "Song details not found", http.StatusNotFound)
+	var fetchErr error
+	if policy != policyDeferred || dryRun {
+		songDetail, fetchErr = enricher.Fetch(r.Context(), input.GroupName, input.SongName)
+		if r.Context().Err() != nil {
+			slog.Warn("Request cancelled while fetching song details", "error", fetchErr)
 			return
-		} else {
-			http.Error(w, "Failed to fetch song details", http.StatusInternalServerError)
+		}
+		if !hasDetails(fetchErr) && policy == policyStrict {
+			if errors.Is(fetchErr, errSongDetailsNotFound) {
+				slog.Warn("Song details not found", "group", input.GroupName, "song", input.SongName)
+				http.Error(w, "Song details not found", http.StatusNotFound)
+			} else {
+				slog.Warn("Failed to fetch song details", "error", fetchErr)
+				http.Error(w, "Failed to fetch song details", http.StatusBadGateway)
+			}
 			return
 		}
-	} else {
-		defer resp.Body.Close()
+	}
 
-		if err := json.NewDecoder(resp.Body).Decode(&songDetail); err != nil {
-			slog.Warn("Failed to decode API response, using placeholders", "error", err)
-		}
+	if dryRun {
+		previewSong(w, input, songDetail, fetchErr)
+		return
 	}
 
 	var groupID int
-	err = db.QueryRow("SELECT id_group FROM musicGroups WHERE groupName = $1", input.GroupName).Scan(&groupID)
+	err := db.QueryRow("SELECT id_group FROM musicGroups WHERE groupName = $1", input.GroupName).Scan(&groupID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			err = db.QueryRow(
@@ -124,29 +187,139 @@ func addSong(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	query := `
-        INSERT INTO songs (id_group, song, release_date, lyrics, link)
-        VALUES ($1, $2, $3, $4, $5)`
-	_, err = db.Exec(query, groupID, input.SongName, songDetail.ReleaseDate, songDetail.Lyrics, songDetail.Link)
+	tx, err := db.Beginx()
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		http.Error(w, "Failed to add song", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var idSong int
+	err = tx.QueryRow(
+		"INSERT INTO songs (id_group, song, lyrics) VALUES ($1, $2, '') RETURNING id_song",
+		groupID, input.SongName,
+	).Scan(&idSong)
 	if err != nil {
 		slog.Error("Failed to insert song", "error", err)
 		http.Error(w, "Failed to add song", http.StatusInternalServerError)
 		return
 	}
 
+	switch {
+	case policy == policyDeferred:
+		err = queue.Enqueue(tx, idSong, false)
+	case fetchErr == nil:
+		err = storeSongDetail(tx, idSong, songDetail)
+	case hasDetails(fetchErr):
+		// Some providers failed for now; the queue fetches what they would have supplied.
+		slog.Warn("Song details fetched partly, queueing the rest", "error", fetchErr)
+		if err = storeSongDetail(tx, idSong, songDetail); err == nil {
+			err = queue.Enqueue(tx, idSong, false)
+		}
+	case errors.Is(fetchErr, errSongDetailsNotFound):
+		slog.Warn("Song details not found, storing without details", "group", input.GroupName, "song", input.SongName)
+		err = setEnrichmentStatus(tx, idSong, enrichmentNotFound, fetchErr)
+	default:
+		slog.Warn("Failed to fetch song details, storing without details", "error", fetchErr)
+		err = setEnrichmentStatus(tx, idSong, enrichmentFailed, fetchErr)
+	}
+	if err != nil {
+		slog.Error("Failed to store song details", "error", err)
+		http.Error(w, "Failed to add song", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("Failed to commit song", "error", err)
+		http.Error(w, "Failed to add song", http.StatusInternalServerError)
+		return
+	}
+	if policy == policyDeferred || fetchErr != nil && hasDetails(fetchErr) {
+		queue.Notify()
+	}
+
+	song, err := fetchSongWithIDs(idSong)
+	if err != nil {
+		slog.Error("Failed to fetch added song", "error", err)
+		http.Error(w, "Failed to add song", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("Song added successfully"))
+	json.NewEncoder(w).Encode(song)
+
+	slog.Info("Song added successfully", "group", input.GroupName, "song", input.SongName,
+		"id_song", idSong, "policy", policy, "enrichment_status", song.Enrichment)
+}
+
+// previewSong responds with the song addSong would store, without writing anything.
+func previewSong(w http.ResponseWriter, input SongShort, detail SongDetail, fetchErr error) {
+	preview := SongPreview{Song: Song{GroupName: input.GroupName, SongName: input.SongName}}
+
+	err := db.Get(&preview.Song.GroupID, "SELECT id_group FROM musicGroups WHERE groupName = $1", input.GroupName)
+	switch {
+	case err == sql.ErrNoRows:
+		preview.GroupCreated = true
+	case err != nil:
+		slog.Error("Database error while fetching group ID", "error", err)
+		http.Error(w, "Failed to preview song", http.StatusInternalServerError)
+		return
+	default:
+		err = db.Select(&preview.ExistingSongs,
+			"SELECT id_song FROM songs WHERE id_group = $1 AND song = $2 ORDER BY id_song",
+			preview.Song.GroupID, input.SongName)
+		if err != nil {
+			slog.Error("Failed to look up existing songs", "error", err)
+			http.Error(w, "Failed to preview song", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	switch {
+	case hasDetails(fetchErr):
+		sanitizeSongDetail(0, &detail)
+		preview.Song.Enrichment = enrichmentDone
+		if fetchErr != nil {
+			preview.Song.Enrichment = enrichmentPending
+			preview.Error = fetchErr.Error()
+		}
+		preview.Song.ReleaseDate, preview.Song.Precision = detail.ReleaseDate, detail.Precision
+		preview.Song.Lyrics = detail.Lyrics
+		preview.Song.Link = detail.Link
+		preview.Song.Sources = detail.Sources
+		preview.Song.AudioMetadata = detail.AudioMetadata
+		if detail.Link != "" {
+			_, provider, _ := parseLink(detail.Link)
+			preview.Song.Links = []SongLink{{URL: detail.Link, Provider: provider}}
+		}
+		preview.Problems = detail.Problems
+	case errors.Is(fetchErr, errSongDetailsNotFound):
+		preview.Song.Enrichment = enrichmentNotFound
+		preview.Error = fetchErr.Error()
+	default:
+		preview.Song.Enrichment = enrichmentFailed
+		preview.Error = fetchErr.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
 
-	slog.Info("Song added successfully", "group", input.GroupName, "song", input.SongName)
+	slog.Info("Song previewed", "group", input.GroupName, "song", input.SongName,
+		"group_created", preview.GroupCreated, "enrichment_status", preview.Song.Enrichment)
 }
 
 // @Summary Music info
-// @Description Get releaseDate, text, link for a song based on group and song.
+// @Description Get releaseDate, text, link for a song based on group and song. The text comes in the language negotiated from lang or Accept-Language, falling back to the original lyrics; Content-Language names it when known. With clean=true profane words of the text are masked.
 // @Tags songs
 // @Accept  json
 // @Produce  json
 // @Param group query string true "Group of the song"
 // @Param song query string true "Title of the song"
+// @Param lang query string false "Language of the text; overrides Accept-Language"
+// @Param Accept-Language header string false "Preferred languages of the text"
+// @Param clean query bool false "Mask words of the profanity lexicon, keeping their first letter"
 // @Success 200 {object} SongDetail "Song details"
 // @Failure 400 {string} string "Missing required parameters 'group' or 'song'"
 // @Failure 404 {string} string "Song not found"
@@ -163,15 +336,24 @@ func getSongInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	clean, ok := cleanParam(w, r)
+	if !ok {
+		return
+	}
+
 	slog.Debug("Fetching song info", "group", groupName, "song", songName)
 
-	var detail SongDetail
+	var song struct {
+		SongDetail
+		ID       int     `db:"id_song"`
+		Language *string `db:"language"`
+	}
 	query := `
-		SELECT s.release_date, s.lyrics, s.link 
+		SELECT s.id_song, s.language, ` + releaseDateColumnsSQL + `, s.lyrics, ` + primaryLinkSQL + `, s.detail_sources, ` + audioMetadataColumnsSQL + `
 		FROM songs s
 		JOIN musicGroups g ON s.id_group = g.id_group
 		WHERE g.groupName = $1 AND s.song = $2`
-	err := db.Get(&detail, query, groupName, songName)
+	err := db.Get(&song, query, groupName, songName)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			slog.Warn("Song not found", "group", groupName, "song", songName)
@@ -183,6 +365,27 @@ func getSongInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	detail := song.SongDetail
+	language, lyrics, _, err := translatedLyrics(r, song.ID, detail.Lyrics, song.Language)
+	if err != nil {
+		writeTranslationError(w, song.ID, err)
+		return
+	}
+	detail.Lyrics = lyrics
+	if clean {
+		masker, err := lyricsMasker(language)
+		if err != nil {
+			slog.Error("Failed to load profanity lexicon", "error", err)
+			http.Error(w, "Failed to fetch song details", http.StatusInternalServerError)
+			return
+		}
+		detail.Lyrics = masker.mask(detail.Lyrics)
+	}
+	w.Header().Set("Vary", "Accept-Language")
+	if language != "" {
+		w.Header().Set("Content-Language", language)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(detail)
 
@@ -190,15 +393,17 @@ func getSongInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update song details
-// @Description Update the details of an existing song.
+// @Description Update the details of an existing song. The lyrics language is detected from the text unless language is given, which sets it by hand; an empty language goes back to detection.
 // @Tags songs
 // @Accept  json
 // @Produce  json
 // @Param id_song query int true "ID of the song"
 // @Param song body Song true "Updated song details"
+// @Param X-Author header string false "Who makes the change, kept in the lyrics history (default anonymous)"
 // @Success 200 {object} Song "The updated song"
 // @Failure 400 {string} string "Invalid input"
 // @Failure 404 {string} string "Song not found"
+// @Failure 409 {string} string "ISRC already used by another song, or link the song already has"
 // @Failure 500 {string} string "Failed to update song"
 // @Router /songs [put]
 func updateSong(w http.ResponseWriter, r *http.Request) {
@@ -225,25 +430,112 @@ func updateSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// An omitted release date keeps the stored one; its precision follows from the format.
+	releaseDate, precision, err := releaseDateArgs(song.ReleaseDate)
+	if err != nil {
+		slog.Warn("Invalid release date", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := song.AudioMetadata.validate(); err != nil {
+		slog.Warn("Invalid audio metadata", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	song.Lyrics = normalizeLyrics(song.Lyrics)
+
+	// An omitted identifier keeps the stored one, an empty string clears it.
+	for _, id := range []struct {
+		value     *string
+		normalize func(string) (string, error)
+	}{
+		{song.ISRC, normalizeISRC},
+		{song.ISWC, normalizeISWC},
+	} {
+		if id.value == nil || *id.value == "" {
+			continue
+		}
+		normalized, err := id.normalize(*id.value)
+		if err != nil {
+			slog.Warn("Invalid identifier", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*id.value = normalized
+	}
+
+	if song.Language != nil && *song.Language != "" {
+		language, err := normalizeLanguageTag(*song.Language)
+		if err != nil {
+			slog.Warn("Invalid language", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		song.Language = &language
+	}
+
+	if song.Link != "" {
+		if _, _, err := parseLink(song.Link); err != nil {
+			slog.Warn("Invalid link", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	query := `
         UPDATE songs
         SET 
             id_group = COALESCE($1, id_group),
             song = COALESCE($2, song),
             release_date = COALESCE($3, release_date),
-            lyrics = COALESCE($4, lyrics),
-            link = COALESCE($5, link)
-        WHERE id_song = $6`
+            release_date_precision = COALESCE($4, release_date_precision),
+            lyrics = COALESCE($5, lyrics),
+            isrc = CASE WHEN $6::text IS NULL THEN isrc ELSE NULLIF($6, '') END,
+            iswc = CASE WHEN $7::text IS NULL THEN iswc ELSE NULLIF($7, '') END,
+            duration = COALESCE($8, duration),
+            bpm = COALESCE($9, bpm),
+            musical_key = COALESCE($10, musical_key),
+            time_signature = COALESCE($11, time_signature),
+            explicit = COALESCE($12, explicit),
+            explicit_source = CASE WHEN $12::boolean IS NULL THEN explicit_source ELSE '` + explicitManual + `' END,
+            language = CASE WHEN $13::text IS NULL THEN language ELSE NULLIF($13, '') END,
+            language_confidence = CASE WHEN $13::text IS NULL THEN language_confidence END,
+            language_source = CASE WHEN $13::text IS NULL THEN language_source WHEN $13 <> '' THEN '` + languageManual + `' END
+        WHERE id_song = $14`
+
+	tx, err := db.Beginx()
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		http.Error(w, "Failed to update song details", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
 
-	result, err := db.Exec(query,
+	result, err := tx.Exec(query,
 		song.GroupID,
 		song.SongName,
-		song.ReleaseDate,
+		releaseDate,
+		precision,
 		song.Lyrics,
-		song.Link,
+		song.ISRC,
+		song.ISWC,
+		song.Duration,
+		song.BPM,
+		song.Key,
+		song.TimeSignature,
+		song.Explicit,
+		song.Language,
 		idSong)
 
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			slog.Warn("ISRC already in use", "id_song", idSong)
+			http.Error(w, "ISRC already used by another song", http.StatusConflict)
+			return
+		}
 		slog.Error("Failed to update song details", "error", err)
 		http.Error(w, "Failed to update song details", http.StatusInternalServerError)
 		return
@@ -256,6 +548,46 @@ func updateSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := syncLyricSections(tx, idSong); err != nil {
+		slog.Error("Failed to update lyric sections", "error", err)
+		http.Error(w, "Failed to update song details", http.StatusInternalServerError)
+		return
+	}
+	if err := updateLyricsLanguage(tx, idSong); err != nil {
+		slog.Error("Failed to detect lyrics language", "error", err)
+		http.Error(w, "Failed to update song details", http.StatusInternalServerError)
+		return
+	}
+	if err := updateExplicitFlag(tx, idSong); err != nil {
+		slog.Error("Failed to check lyrics for profanity", "error", err)
+		http.Error(w, "Failed to update song details", http.StatusInternalServerError)
+		return
+	}
+	if _, _, err := recordLyricsRevision(tx, idSong, revisionAuthor(r), revisionEdit, 0); err != nil {
+		slog.Error("Failed to record lyrics revision", "error", err)
+		http.Error(w, "Failed to update song details", http.StatusInternalServerError)
+		return
+	}
+	// The single link field replaces the song's primary link; the rest are managed via /songs/{id_song}/links.
+	if song.Link != "" {
+		if err := replacePrimaryLink(tx, idSong, song.Link); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				slog.Warn("Link already exists", "id_song", idSong, "url", song.Link)
+				http.Error(w, "Song already has this link", http.StatusConflict)
+				return
+			}
+			slog.Error("Failed to update song link", "error", err)
+			http.Error(w, "Failed to update song details", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Failed to commit song update", "error", err)
+		http.Error(w, "Failed to update song details", http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 
 	slog.Debug("Song details updated successfully", "id_song", idSong)
@@ -309,16 +641,24 @@ func deleteSong(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get song text with pagination
-// @Description Fetch the song text with pagination by verses.
+// @Description Fetch the song text, or a page of it by verses, lines or characters, in the language negotiated from lang or Accept-Language and named by Content-Language. Text responses carry the totals in X-Total-Items, X-Total-Pages and X-Page headers.
 // @Tags songs
 // @Accept  json
 // @Produce  text/plain
+// @Produce  json
 // @Param id_song query int true "ID of the song"
-// @Param page query int false "Page number (default is 1)"
-// @Param limit query int false "Number of verses per page (default is 1)"
+// @Param page query int false "Page number (default is 1); pages past the end are empty"
+// @Param limit query int false "Number of units per page (default is 1 verse, 10 lines or 1000 characters; every section with format=json and unit=verse)"
+// @Param unit query string false "verse (default), line or char. Text verses are the blank-line separated blocks as written, headers included; JSON verses are the sections recognized from markup like [Chorus] or Verse 2:, which total_verses counts for every unit"
+// @Param format query string false "text (default) or json, an envelope with the totals, page count and sections"
+// @Param lang query string false "Language of the text, a translation or the original lyrics; overrides Accept-Language, and a language without lyrics is a 404"
+// @Param Accept-Language header string false "Preferred languages of the text"
+// @Param interleave query bool false "Follow each original section with the translated one of the same type and number, like the second chorus (unit=verse only); pages are then sections in both formats"
+// @Param clean query bool false "Mask words of the profanity lexicon of the text's language with asterisks, keeping their first letter and the length of the text"
 // @Success 200 {string} string "Song text or a portion of it"
+// @Success 200 {object} SongTextPage "Page of the song text (format=json)"
 // @Failure 400 {string} string "Invalid parameters"
-// @Failure 404 {string} string "Song not found or no text available"
+// @Failure 404 {string} string "Song not found, no text or no translation available"
 // @Failure 500 {string} string "Failed to fetch song text"
 // @Router /songs/text [get]
 func getSongText(w http.ResponseWriter, r *http.Request) {
@@ -338,32 +678,45 @@ func getSongText(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Debug("Fetching song text", "id_song", idSong)
-
-	var text string
-	query := `SELECT lyrics FROM songs WHERE id_song = $1`
-	err = db.Get(&text, query, idSong)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			slog.Warn("Song not found", "id_song", idSong)
-			http.Error(w, "Song not found", http.StatusNotFound)
-		} else {
-			slog.Error("Failed to fetch song text", "error", err)
-			http.Error(w, "Failed to fetch song text", http.StatusInternalServerError)
-		}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "text" && format != "json" {
+		slog.Warn("Invalid format parameter", "format", format)
+		http.Error(w, "Invalid 'format' parameter, expected text or json", http.StatusBadRequest)
 		return
 	}
 
-	if text == "" {
-		slog.Warn("No text available for song", "id_song", idSong)
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("No text available for this song"))
+	unit := r.URL.Query().Get("unit")
+	if unit == "" {
+		unit = unitVerse
+	}
+	limit, ok := defaultPageLimits[unit]
+	if !ok {
+		slog.Warn("Invalid unit parameter", "unit", unit)
+		http.Error(w, "Invalid 'unit' parameter, expected verse, line or char", http.StatusBadRequest)
 		return
 	}
 
-	verses := strings.Split(text, "\n\n")
-	page, limit := 1, 1
+	interleave := false
+	if value := r.URL.Query().Get("interleave"); value != "" {
+		interleave, err = strconv.ParseBool(value)
+		if err != nil {
+			slog.Warn("Invalid interleave parameter", "interleave", value)
+			http.Error(w, "Invalid 'interleave' parameter", http.StatusBadRequest)
+			return
+		}
+		if interleave && unit != unitVerse {
+			slog.Warn("Interleaving requires verse unit", "unit", unit)
+			http.Error(w, "Interleaved mode pages by verse only", http.StatusBadRequest)
+			return
+		}
+	}
+
+	clean, ok := cleanParam(w, r)
+	if !ok {
+		return
+	}
 
+	page := 1
 	pageStr := r.URL.Query().Get("page")
 	if pageStr != "" {
 		page, err = strconv.Atoi(pageStr)
@@ -384,38 +737,192 @@ func getSongText(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	start := (page - 1) * limit
-	end := start + limit
+	slog.Debug("Fetching song text", "id_song", idSong)
 
-	if start >= len(verses) {
-		slog.Warn("Page out of range", "page", page)
-		http.Error(w, "Page out of range", http.StatusBadRequest)
+	var song struct {
+		Lyrics   string  `db:"lyrics"`
+		Language *string `db:"language"`
+	}
+	query := `SELECT lyrics, language FROM songs WHERE id_song = $1`
+	err = db.Get(&song, query, idSong)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+		} else {
+			slog.Error("Failed to fetch song text", "error", err)
+			http.Error(w, "Failed to fetch song text", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	language, text, translated, err := translatedLyrics(r, idSong, song.Lyrics, song.Language)
+	if err != nil {
+		writeTranslationError(w, idSong, err)
+		return
+	}
+	if interleave && !translated {
+		slog.Warn("No translation to interleave", "id_song", idSong)
+		http.Error(w, "No translation available in the requested language", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Vary", "Accept-Language")
+	if language != "" {
+		w.Header().Set("Content-Language", language)
+	}
+
+	if text == "" && format != "json" {
+		slog.Warn("No text available for song", "id_song", idSong)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("No text available for this song"))
 		return
 	}
 
-	if end > len(verses) {
-		end = len(verses)
+	// Masking comes before paging, so a word split across a page boundary is still found whole.
+	var masker profanityMatcher
+	if clean {
+		languages := []string{language}
+		if interleave && song.Language != nil {
+			languages = append(languages, *song.Language)
+		} else if interleave {
+			languages = append(languages, "")
+		}
+		if masker, err = lyricsMasker(languages...); err != nil {
+			slog.Error("Failed to load profanity lexicon", "error", err)
+			http.Error(w, "Failed to fetch song text", http.StatusInternalServerError)
+			return
+		}
+		text = masker.mask(text)
+		if interleave {
+			song.Lyrics = masker.mask(song.Lyrics)
+		}
 	}
 
-	paginatedVerses := verses[start:end]
-	responseText := strings.Join(paginatedVerses, "\n\n")
+	verses, lines, chars := splitVerses(text), splitLines(text), []rune(normalizeLyrics(text))
+	textPage := SongTextPage{
+		SongID:      idSong,
+		Unit:        unit,
+		Page:        page,
+		TotalVerses: len(verses),
+		TotalLines:  len(lines),
+		TotalChars:  len(chars),
+		Language:    language,
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(responseText))
+	var sections []LyricSection
+	switch {
+	case interleave:
+		// Pages follow the original's sections, each paired with its translation.
+		original, err := lyricSections(idSong, song.Lyrics)
+		if err != nil {
+			slog.Error("Failed to fetch lyric sections", "error", err)
+			http.Error(w, "Failed to fetch song text", http.StatusInternalServerError)
+			return
+		}
+		sections = alignSections(original, parseLyricSections(text))
+		textPage.Language, textPage.TranslationLanguage = "", language
+		if song.Language != nil {
+			textPage.Language = *song.Language
+		}
+		verses, lines, chars = splitVerses(song.Lyrics), splitLines(song.Lyrics), []rune(normalizeLyrics(song.Lyrics))
+		textPage.TotalVerses, textPage.TotalLines, textPage.TotalChars = len(sections), len(lines), len(chars)
+		if format == "json" && limitStr == "" {
+			limit = max(len(sections), 1)
+		}
+	case format == "json":
+		// JSON verses are sections whatever the unit, so total_verses is the same on every page.
+		// Stored sections belong to the original; a translation is parsed on the fly.
+		if translated {
+			sections = parseLyricSections(text)
+		} else if sections, err = lyricSections(idSong, text); err != nil {
+			slog.Error("Failed to fetch lyric sections", "error", err)
+			http.Error(w, "Failed to fetch song text", http.StatusInternalServerError)
+			return
+		}
+		if sections == nil {
+			sections = []LyricSection{}
+		}
+		textPage.TotalVerses = len(sections)
+		if unit == unitVerse && limitStr == "" {
+			limit = max(len(sections), 1)
+		}
+	}
+	textPage.Limit = limit
+	if clean {
+		// Stored sections hold the lyrics as written.
+		for i := range sections {
+			section := &sections[i]
+			section.Lines, section.Translation = masker.maskLines(section.Lines), masker.maskLines(section.Translation)
+		}
+	}
 
-	slog.Debug("Song text fetched successfully", "id_song", idSong, "page", page, "limit", limit)
+	var total int
+	switch unit {
+	case unitVerse:
+		total = textPage.TotalVerses
+	case unitLine:
+		total = textPage.TotalLines
+	case unitChar:
+		total = textPage.TotalChars
+	}
+	start, end, totalPages := pageBounds(total, page, limit)
+	textPage.TotalPages, textPage.HasNext = totalPages, page < totalPages
+
+	switch {
+	case sections != nil && unit == unitVerse:
+		textPage.Sections = sections[start:end]
+		blocks := make([]string, 0, end-start)
+		for _, section := range textPage.Sections {
+			blocks = append(blocks, strings.Join(section.Lines, "\n"))
+			if interleave {
+				blocks = append(blocks, strings.Join(section.Translation, "\n"))
+			}
+		}
+		textPage.Text = strings.Join(blocks, "\n\n")
+	case unit == unitVerse:
+		textPage.Text = strings.Join(verses[start:end], "\n\n")
+	case unit == unitLine:
+		textPage.Text = strings.Join(lines[start:end], "\n")
+	case unit == unitChar:
+		textPage.Text = string(chars[start:end])
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(textPage)
+	} else {
+		w.Header().Set("X-Total-Items", strconv.Itoa(total))
+		w.Header().Set("X-Total-Pages", strconv.Itoa(totalPages))
+		w.Header().Set("X-Page", strconv.Itoa(page))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(textPage.Text))
+	}
+
+	slog.Debug("Song text fetched successfully", "id_song", idSong, "unit", unit, "page", page, "total_pages", totalPages)
 }
 
 // @Summary Get songs with optional filters and pagination
-// @Description Retrieve a list of songs with optional filters: group name, song name, release date, text, link, and pagination by songs.
+// @Description Retrieve a list of songs with optional filters: group name, song name, release date, text, link, tempo, duration, key, time signature, explicit flag, lyrics language, and pagination by songs.
 // @Tags songs
 // @Accept  json
 // @Produce  json
 // @Param group query string false "Filter by group name"
 // @Param song query string false "Filter by song name"
-// @Param release_date query string false "Filter by release date (YYYY-MM-DD)"
+// @Param release_date query string false "Filter by release date (YYYY, YYYY-MM or YYYY-MM-DD); matches songs known at least to that precision within the period"
+// @Param release_date_from query string false "Songs released on or after the start of this period (YYYY, YYYY-MM or YYYY-MM-DD)"
+// @Param release_date_to query string false "Songs released on or before the end of this period (YYYY, YYYY-MM or YYYY-MM-DD)"
+// @Param bpm_min query number false "Minimum tempo in BPM"
+// @Param bpm_max query number false "Maximum tempo in BPM"
+// @Param duration_min query int false "Minimum duration in seconds"
+// @Param duration_max query int false "Maximum duration in seconds"
+// @Param key query string false "Musical key, e.g. C#m"
+// @Param time_signature query string false "Time signature, e.g. 3/4"
+// @Param explicit query bool false "Filter by explicit-content flag"
+// @Param sort query string false "Sort order: release_date or -release_date (default is by ID)"
 // @Param text query string false "Filter by text"
 // @Param link query string false "Filter by link"
+// @Param provider query string false "Only songs with a link of this provider: youtube, spotify, bandcamp, soundcloud, generic"
+// @Param language query string false "Language of the lyrics, e.g. en (also matches en-GB) or pt-BR; und for songs without a known language"
 // @Param page query int false "Page number (default is 1)"
 // @Param limit query int false "Number of songs per page (default is 10)"
 // @Success 200 {array} Song "Paginated list of songs"
@@ -425,11 +932,7 @@ func getSongText(w http.ResponseWriter, r *http.Request) {
 func getSongsFiltered(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Received request to getSongsFiltered")
 
-	group := r.URL.Query().Get("group")
-	song := r.URL.Query().Get("song")
-	releaseDate := r.URL.Query().Get("release_date")
-	text := r.URL.Query().Get("text")
-	link := r.URL.Query().Get("link")
+	sort := r.URL.Query().Get("sort")
 
 	page, limit := 1, 10
 
@@ -455,36 +958,32 @@ func getSongsFiltered(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	filters, args, err := songFilters(r.URL.Query(), "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	query := `
-        SELECT s.id_song, s.id_group, g.groupName AS group, s.song, s.release_date, s.lyrics, s.link
+        SELECT ` + songColumnsSQL + `
         FROM songs s
         INNER JOIN musicGroups g ON s.id_group = g.id_group
-        WHERE 1=1`
-	args := []interface{}{}
-
-	if group != "" {
-		query += " AND g.groupName ILIKE $" + strconv.Itoa(len(args)+1)
-		args = append(args, "%"+group+"%")
-	}
-	if song != "" {
-		query += " AND s.song ILIKE $" + strconv.Itoa(len(args)+1)
-		args = append(args, "%"+song+"%")
-	}
-	if releaseDate != "" {
-		query += " AND s.release_date = $" + strconv.Itoa(len(args)+1)
-		args = append(args, releaseDate)
-	}
-	if text != "" {
-		query += " AND s.lyrics ILIKE $" + strconv.Itoa(len(args)+1)
-		args = append(args, "%"+text+"%")
-	}
-	if link != "" {
-		query += " AND s.link ILIKE $" + strconv.Itoa(len(args)+1)
-		args = append(args, "%"+link+"%")
+        WHERE 1=1` + filters
+
+	switch sort {
+	case "":
+		query += " ORDER BY s.id_song"
+	case "release_date":
+		query += " ORDER BY s.release_date ASC NULLS LAST, " + precisionRankSQL + " ASC, s.id_song"
+	case "-release_date":
+		query += " ORDER BY s.release_date DESC NULLS LAST, " + precisionRankSQL + " DESC, s.id_song"
+	default:
+		slog.Warn("Invalid sort parameter", "sort", sort)
+		http.Error(w, "Invalid sort parameter", http.StatusBadRequest)
+		return
 	}
 
 	var allSongs []Song
-	err := db.Select(&allSongs, query, args...)
+	err = db.Select(&allSongs, query, args...)
 	if err != nil {
 		slog.Error("Failed to fetch songs", "error", err)
 		http.Error(w, "Failed to fetch songs", http.StatusInternalServerError)
@@ -506,8 +1005,132 @@ func getSongsFiltered(w http.ResponseWriter, r *http.Request) {
 
 	paginatedSongs := allSongs[start:end]
 
+	ids := make([]int64, 0, len(paginatedSongs))
+	for _, song := range paginatedSongs {
+		ids = append(ids, int64(song.ID))
+	}
+	links, err := songLinks(ids)
+	if err != nil {
+		slog.Error("Failed to fetch song links", "error", err)
+		http.Error(w, "Failed to fetch songs", http.StatusInternalServerError)
+		return
+	}
+	for i := range paginatedSongs {
+		paginatedSongs[i].Links = links[paginatedSongs[i].ID]
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(paginatedSongs)
 
 	slog.Debug("Songs retrieved successfully", "count", len(paginatedSongs), "page", page, "limit", limit)
 }
+
+// songFilters builds the conditions of GET /songs from its query parameters, each prefixed with AND,
+// with the arguments they refer to. The filter named by except is left out, so facets can count the
+// values it would choose between. Errors are fit to be shown to the client.
+func songFilters(params url.Values, except string) (string, []interface{}, error) {
+	get := func(name string) string {
+		if name == except {
+			return ""
+		}
+		return params.Get(name)
+	}
+
+	query := ""
+	args := []interface{}{}
+
+	if group := get("group"); group != "" {
+		query += " AND g.groupName ILIKE $" + strconv.Itoa(len(args)+1)
+		args = append(args, "%"+group+"%")
+	}
+	if song := get("song"); song != "" {
+		query += " AND s.song ILIKE $" + strconv.Itoa(len(args)+1)
+		args = append(args, "%"+song+"%")
+	}
+	if releaseDate := get("release_date"); releaseDate != "" {
+		date, precision, err := parseReleaseDate(releaseDate)
+		if err != nil {
+			slog.Warn("Invalid release_date parameter", "release_date", releaseDate)
+			return "", nil, err
+		}
+		query += " AND s.release_date >= $" + strconv.Itoa(len(args)+1)
+		query += " AND s.release_date < $" + strconv.Itoa(len(args)+2)
+		query += " AND " + precisionRankSQL + " >= $" + strconv.Itoa(len(args)+3)
+		args = append(args, date, releaseDatePeriodEnd(date, precision), precisionRank(precision))
+	}
+	if from := get("release_date_from"); from != "" {
+		date, _, err := parseReleaseDate(from)
+		if err != nil {
+			slog.Warn("Invalid release_date_from parameter", "release_date_from", from)
+			return "", nil, err
+		}
+		query += " AND s.release_date >= $" + strconv.Itoa(len(args)+1)
+		args = append(args, date)
+	}
+	if to := get("release_date_to"); to != "" {
+		date, precision, err := parseReleaseDate(to)
+		if err != nil {
+			slog.Warn("Invalid release_date_to parameter", "release_date_to", to)
+			return "", nil, err
+		}
+		query += " AND s.release_date < $" + strconv.Itoa(len(args)+1)
+		args = append(args, releaseDatePeriodEnd(date, precision))
+	}
+	if text := get("text"); text != "" {
+		query += " AND s.lyrics ILIKE $" + strconv.Itoa(len(args)+1)
+		args = append(args, "%"+text+"%")
+	}
+	if link := get("link"); link != "" {
+		query += " AND EXISTS (SELECT 1 FROM song_links l WHERE l.id_song = s.id_song AND l.url ILIKE $" + strconv.Itoa(len(args)+1) + ")"
+		args = append(args, "%"+link+"%")
+	}
+	if provider := get("provider"); provider != "" {
+		if !linkProviders[provider] {
+			slog.Warn("Invalid provider parameter", "provider", provider)
+			return "", nil, errors.New("Invalid provider parameter")
+		}
+		query += " AND EXISTS (SELECT 1 FROM song_links l WHERE l.id_song = s.id_song AND l.provider = $" + strconv.Itoa(len(args)+1) + ")"
+		args = append(args, provider)
+	}
+	// A bare language matches its regional variants too, en matches en-GB; und matches songs without one.
+	if language := get("language"); language != "" {
+		tag, err := normalizeLanguageTag(language)
+		if err != nil {
+			slog.Warn("Invalid language parameter", "language", language)
+			return "", nil, errors.New("Invalid language parameter")
+		}
+		if tag == undeterminedLanguage {
+			query += " AND s.language IS NULL"
+		} else {
+			n := strconv.Itoa(len(args) + 1)
+			query += " AND (s.language = $" + n + " OR s.language LIKE $" + n + " || '-%')"
+			args = append(args, tag)
+		}
+	}
+
+	for _, f := range []struct {
+		param, column, op string
+		parse             func(string) (interface{}, error)
+	}{
+		{"bpm_min", "s.bpm", ">=", parseFloatParam},
+		{"bpm_max", "s.bpm", "<=", parseFloatParam},
+		{"duration_min", "s.duration", ">=", parseIntParam},
+		{"duration_max", "s.duration", "<=", parseIntParam},
+		{"key", "s.musical_key", "=", parseKeyParam},
+		{"time_signature", "s.time_signature", "=", parseTimeSignatureParam},
+		{"explicit", "s.explicit", "=", parseBoolParam},
+	} {
+		value := get(f.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := f.parse(value)
+		if err != nil {
+			slog.Warn("Invalid "+f.param+" parameter", f.param, value)
+			return "", nil, errors.New("Invalid " + f.param + " parameter")
+		}
+		query += " AND " + f.column + " " + f.op + " $" + strconv.Itoa(len(args)+1)
+		args = append(args, parsed)
+	}
+	return query, args, nil
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// languageTagRe matches BCP 47 tags in the forms we accept: a language optionally followed by a
+// script and a region, like en, pt-BR, zh-Hant or sr-Latn-RS.
+var languageTagRe = regexp.MustCompile(`^([a-zA-Z]{2,3})(?:-([a-zA-Z]{4}))?(?:-([a-zA-Z]{2}|[0-9]{3}))?$`)
+
+var (
+	// errNoTranslation means the song has no lyrics in the requested language.
+	errNoTranslation   = errors.New("no lyrics in the requested language")
+	errInvalidLanguage = errors.New("invalid language tag")
+)
+
+// normalizeLanguageTag returns the canonical form of a language tag: "PT-br" becomes "pt-BR".
+func normalizeLanguageTag(tag string) (string, error) {
+	m := languageTagRe.FindStringSubmatch(strings.TrimSpace(strings.ReplaceAll(tag, "_", "-")))
+	if m == nil {
+		return "", fmt.Errorf("%w %q", errInvalidLanguage, tag)
+	}
+	parts := []string{strings.ToLower(m[1])}
+	if m[2] != "" {
+		parts = append(parts, strings.ToUpper(m[2][:1])+strings.ToLower(m[2][1:]))
+	}
+	if m[3] != "" {
+		parts = append(parts, strings.ToUpper(m[3]))
+	}
+	return strings.Join(parts, "-"), nil
+}
+
+// primaryLanguage returns the language subtag of a tag, "pt" for "pt-BR".
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(tag, "-")
+	return strings.ToLower(primary)
+}
+
+// parseAcceptLanguage returns the languages of an Accept-Language header, most preferred first.
+// Invalid entries and those with q=0 are skipped; "*" is kept.
+func parseAcceptLanguage(header string) []string {
+	type preference struct {
+		tag string
+		q   float64
+	}
+	var prefs []preference
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		if tag != "*" {
+			var err error
+			if tag, err = normalizeLanguageTag(tag); err != nil {
+				continue
+			}
+		}
+		prefs = append(prefs, preference{tag, q})
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	tags := make([]string, len(prefs))
+	for i, pref := range prefs {
+		tags[i] = pref.tag
+	}
+	return tags
+}
+
+// negotiateLanguage picks the lyrics to serve for the preferred languages: original, the language of
+// the song's own lyrics, or one of its translations. A preference matches a language exactly or, failing
+// that, by its primary subtag, so "pt" finds "pt-BR". The original is served when nothing matches.
+func negotiateLanguage(prefs []string, original string, translations []string) (string, bool) {
+	for _, pref := range prefs {
+		if pref == "*" || strings.EqualFold(pref, original) {
+			return original, false
+		}
+		for _, language := range translations {
+			if strings.EqualFold(pref, language) {
+				return language, true
+			}
+		}
+		if original != "" && primaryLanguage(pref) == primaryLanguage(original) {
+			return original, false
+		}
+		for _, language := range translations {
+			if primaryLanguage(pref) == primaryLanguage(language) {
+				return language, true
+			}
+		}
+	}
+	return original, false
+}
+
+// translatedLyrics resolves the language a request asks for, with the lang parameter taking precedence
+// over Accept-Language, and returns the language served and its lyrics. translated is false when the
+// original lyrics are served. An explicit lang the song has no lyrics in is errNoTranslation.
+func translatedLyrics(r *http.Request, idSong int, lyrics string, language *string) (string, string, bool, error) {
+	original := ""
+	if language != nil {
+		original = *language
+	}
+
+	var prefs []string
+	explicit := r.URL.Query().Get("lang")
+	if explicit != "" {
+		tag, err := normalizeLanguageTag(explicit)
+		if err != nil {
+			return "", "", false, err
+		}
+		prefs = []string{tag}
+	} else if header := r.Header.Get("Accept-Language"); header != "" {
+		prefs = parseAcceptLanguage(header)
+	}
+	if len(prefs) == 0 {
+		return original, lyrics, false, nil
+	}
+
+	var translations []string
+	if err := db.Select(&translations, "SELECT language FROM song_translations WHERE id_song = $1 ORDER BY language", idSong); err != nil {
+		return "", "", false, err
+	}
+
+	chosen, translated := negotiateLanguage(prefs, original, translations)
+	if !translated {
+		// Without a known original language an explicit lang can't be confirmed as the original.
+		if explicit != "" && !strings.EqualFold(prefs[0], original) &&
+			(original == "" || primaryLanguage(prefs[0]) != primaryLanguage(original)) {
+			return "", "", false, errNoTranslation
+		}
+		return original, lyrics, false, nil
+	}
+
+	var text string
+	if err := db.Get(&text, "SELECT lyrics FROM song_translations WHERE id_song = $1 AND language = $2", idSong, chosen); err != nil {
+		return "", "", false, err
+	}
+	return chosen, text, true, nil
+}
+
+// writeTranslationError answers a failed translatedLyrics call.
+func writeTranslationError(w http.ResponseWriter, idSong int, err error) {
+	switch {
+	case errors.Is(err, errNoTranslation):
+		slog.Warn("No lyrics in requested language", "id_song", idSong)
+		http.Error(w, "No translation available in the requested language", http.StatusNotFound)
+	case errors.Is(err, errInvalidLanguage):
+		slog.Warn("Invalid lang parameter", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.Error("Failed to fetch translation", "error", err)
+		http.Error(w, "Failed to fetch song text", http.StatusInternalServerError)
+	}
+}
+
+// alignSections pairs every original section with its counterpart in a translation: the same occurrence
+// of the same type and number, like the second chorus, then the first section of that type and number,
+// as translations often write a repeated chorus out once, and otherwise the section at the same position.
+func alignSections(original, translated []LyricSection) []LyricSection {
+	type sectionKey struct {
+		sectionType string
+		number      int
+		occurrence  int
+	}
+	keyed := func(sections []LyricSection) []sectionKey {
+		seen := map[sectionKey]int{}
+		keys := make([]sectionKey, len(sections))
+		for i, section := range sections {
+			key := sectionKey{sectionType: section.Type, number: section.Number}
+			key.occurrence = seen[key]
+			seen[key]++
+			keys[i] = key
+		}
+		return keys
+	}
+
+	translatedByKey := map[sectionKey][]string{}
+	for i, key := range keyed(translated) {
+		translatedByKey[key] = translated[i].Lines
+	}
+
+	aligned := make([]LyricSection, len(original))
+	for i, key := range keyed(original) {
+		aligned[i] = original[i]
+		first := sectionKey{sectionType: key.sectionType, number: key.number}
+		if lines, ok := translatedByKey[key]; ok && key.sectionType != sectionUnlabeled {
+			aligned[i].Translation = lines
+		} else if lines, ok := translatedByKey[first]; ok && key.sectionType != sectionUnlabeled {
+			aligned[i].Translation = lines
+		} else if i < len(translated) {
+			aligned[i].Translation = translated[i].Lines
+		} else {
+			aligned[i].Translation = []string{}
+		}
+	}
+	return aligned
+}
+
+// translationLanguageFromPath reads and normalizes the {language} path variable, writing a 400 on error.
+func translationLanguageFromPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	language, err := normalizeLanguageTag(mux.Vars(r)["language"])
+	if err != nil {
+		slog.Warn("Invalid language", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return language, true
+}
+
+const translationColumnsSQL = `id_song, language, lyrics, created_at, updated_at`
+
+// @Summary List lyric translations
+// @Description Get all translations of a song's lyrics, ordered by language.
+// @Tags translations
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Success 200 {array} LyricTranslation "Translations of the song"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to fetch translations"
+// @Router /songs/{id_song}/translations [get]
+func getSongTranslations(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getSongTranslations")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var exists bool
+	if err := db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM songs WHERE id_song = $1)", idSong); err != nil {
+		slog.Error("Failed to fetch song", "error", err)
+		http.Error(w, "Failed to fetch translations", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		slog.Warn("Song not found", "id_song", idSong)
+		http.Error(w, "Song not found", http.StatusNotFound)
+		return
+	}
+
+	translations := []LyricTranslation{}
+	query := "SELECT " + translationColumnsSQL + " FROM song_translations WHERE id_song = $1 ORDER BY language"
+	if err := db.Select(&translations, query, idSong); err != nil {
+		slog.Error("Failed to fetch translations", "error", err)
+		http.Error(w, "Failed to fetch translations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(translations)
+}
+
+// @Summary Get a lyric translation
+// @Description Get the translation of a song's lyrics into one language.
+// @Tags translations
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param language path string true "Language tag, e.g. en or pt-BR"
+// @Success 200 {object} LyricTranslation "The translation"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Translation not found"
+// @Failure 500 {string} string "Failed to fetch translation"
+// @Router /songs/{id_song}/translations/{language} [get]
+func getSongTranslation(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request getSongTranslation")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	language, ok := translationLanguageFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var translation LyricTranslation
+	query := "SELECT " + translationColumnsSQL + " FROM song_translations WHERE id_song = $1 AND language = $2"
+	if err := db.Get(&translation, query, idSong, language); err != nil {
+		if err == sql.ErrNoRows {
+			slog.Warn("Translation not found", "id_song", idSong, "language", language)
+			http.Error(w, "Translation not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to fetch translation", "error", err)
+		http.Error(w, "Failed to fetch translation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(translation)
+}
+
+// @Summary Create or replace a lyric translation
+// @Description Store the translation of a song's lyrics into one language. The text is normalized like the original lyrics and may use the same section markup, so it can be aligned with the original by section.
+// @Tags translations
+// @Accept  json
+// @Produce  json
+// @Param id_song path int true "ID of the song"
+// @Param language path string true "Language tag, e.g. en or pt-BR"
+// @Param input body LyricTranslation true "Translated lyrics in the text field"
+// @Success 200 {object} LyricTranslation "The replaced translation"
+// @Success 201 {object} LyricTranslation "The created translation"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Song not found"
+// @Failure 500 {string} string "Failed to store translation"
+// @Router /songs/{id_song}/translations/{language} [put]
+func putSongTranslation(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request putSongTranslation")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	language, ok := translationLanguageFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var input LyricTranslation
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.Warn("Invalid request body", "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	input.Lyrics = normalizeLyrics(input.Lyrics)
+	if input.Lyrics == "" {
+		slog.Warn("Empty translation", "id_song", idSong, "language", language)
+		http.Error(w, "Translation text is required", http.StatusBadRequest)
+		return
+	}
+
+	var translation struct {
+		LyricTranslation
+		Created bool `db:"created"`
+	}
+	// xmax is zero only for a freshly inserted row, which tells a create from a replace.
+	query := `
+		INSERT INTO song_translations (id_song, language, lyrics) VALUES ($1, $2, $3)
+		ON CONFLICT (id_song, language) DO UPDATE SET lyrics = EXCLUDED.lyrics, updated_at = now()
+		RETURNING ` + translationColumnsSQL + `, (xmax = 0) AS created`
+	if err := db.Get(&translation, query, idSong, language, input.Lyrics); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			slog.Warn("Song not found", "id_song", idSong)
+			http.Error(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to store translation", "error", err)
+		http.Error(w, "Failed to store translation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if translation.Created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(translation.LyricTranslation)
+
+	slog.Debug("Translation stored successfully", "id_song", idSong, "language", language, "created", translation.Created)
+}
+
+// @Summary Delete a lyric translation
+// @Description Remove the translation of a song's lyrics into one language.
+// @Tags translations
+// @Param id_song path int true "ID of the song"
+// @Param language path string true "Language tag, e.g. en or pt-BR"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid parameters"
+// @Failure 404 {string} string "Translation not found"
+// @Failure 500 {string} string "Failed to delete translation"
+// @Router /songs/{id_song}/translations/{language} [delete]
+func deleteSongTranslation(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Received request deleteSongTranslation")
+
+	idSong, ok := songIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	language, ok := translationLanguageFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM song_translations WHERE id_song = $1 AND language = $2", idSong, language)
+	if err != nil {
+		slog.Error("Failed to delete translation", "error", err)
+		http.Error(w, "Failed to delete translation", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		slog.Warn("Translation not found", "id_song", idSong, "language", language)
+		http.Error(w, "Translation not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}